@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+// maxSendBytes allows for the full attachment allowance in base64, which is a
+// third larger than the raw bytes, plus the usual 1MB for the rest of the
+// request.
+const maxSendBytes = data.MaxTotalAttachmentSize*4/3 + 1_048_576
+
+type attachmentInput struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	ContentID   string `json:"content_id"`
+	Content     []byte `json:"content"`
+}
+
+// readSendRequest decodes a send request sent either as JSON, with base64
+// attachment contents, or as multipart/form-data. In the multipart form the
+// JSON request goes in the "payload" field, files in "attachments" fields are
+// attached and files in "inline" fields are embedded with their filename as
+// the Content-ID.
+func (app *application) readSendRequest(w http.ResponseWriter, r *http.Request, dst *sendRequest) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return app.readJSONLimit(w, r, dst, maxSendBytes)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSendBytes)
+
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		if err.Error() == "http: request body too large" {
+			return fmt.Errorf("body must not be larger than %d bytes", maxSendBytes)
+		}
+		return err
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	err = app.decodeJSON(strings.NewReader(r.FormValue("payload")), dst, maxSendBytes)
+	if err != nil {
+		return fmt.Errorf("payload: %w", err)
+	}
+
+	for _, field := range []string{"attachments", "inline"} {
+		for _, fh := range r.MultipartForm.File[field] {
+			content, err := readFormFile(fh)
+			if err != nil {
+				return err
+			}
+
+			a := attachmentInput{
+				Filename:    fh.Filename,
+				ContentType: fh.Header.Get("Content-Type"),
+				Content:     content,
+			}
+			if field == "inline" {
+				a.ContentID = fh.Filename
+			}
+
+			dst.Attachments = append(dst.Attachments, a)
+		}
+	}
+
+	return nil
+}
+
+func readFormFile(fh *multipart.FileHeader) ([]byte, error) {
+	if fh.Size > data.MaxAttachmentSize {
+		return nil, errors.New("attachments must each be at most 10MB")
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// attachmentContentType returns the bare media type of an attachment. When
+// the client did not declare a useful one, it is guessed from the file
+// extension and then from the content itself.
+func attachmentContentType(a attachmentInput) string {
+	mediaType, _, _ := mime.ParseMediaType(a.ContentType)
+
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(a.Filename)))
+	}
+
+	if mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(a.Content))
+	}
+
+	return mediaType
+}
+
+func toAttachments(inputs []attachmentInput) []*data.Attachment {
+	attachments := make([]*data.Attachment, 0, len(inputs))
+
+	for _, a := range inputs {
+		attachments = append(attachments, &data.Attachment{
+			Filename:    filepath.Base(a.Filename),
+			ContentType: attachmentContentType(a),
+			ContentID:   a.ContentID,
+			Content:     a.Content,
+		})
+	}
+
+	return attachments
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+	"github.com/mayura-andrew/email-client/internal/mailer"
+)
+
+// maxBounceBytes bounds a single delivery status notification, which may
+// quote the whole original message.
+const maxBounceBytes = 25 << 20
+
+// createBounceHandler accepts a raw RFC 3464 delivery status notification,
+// for instance piped from the bounce mailbox by the MTA.
+func (app *application) createBounceHandler(w http.ResponseWriter, r *http.Request) {
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBounceBytes))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			err = fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	bounce, err := app.processBounce(raw)
+	if err != nil {
+		switch {
+		case errors.Is(err, mailer.ErrNotDSN):
+			app.failedValidationResponse(w, r, map[string]string{"body": "must be an RFC 3464 delivery status notification"})
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"bounce": bounce}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// processBounce parses a delivery status notification and records its bounce
+// on the recipient named by the original Message-ID. It returns nil when the
+// notification reports no failure or can't be matched to a recipient.
+func (app *application) processBounce(raw []byte) (*data.Bounce, error) {
+	dsn, err := mailer.ParseDSN(bytes.NewReader(raw))
+	if err != nil {
+		if !errors.Is(err, mailer.ErrNotDSN) {
+			err = fmt.Errorf("%w: %v", mailer.ErrNotDSN, err)
+		}
+		return nil, err
+	}
+
+	failure := dsn.Bounce()
+	token := mailer.MessageIDToken(dsn.MessageID)
+
+	if failure == nil || !data.ValidTrackingToken(token) {
+		return nil, nil
+	}
+
+	bounce := &data.Bounce{
+		Type:           failure.BounceType(),
+		Status:         failure.Status,
+		DiagnosticCode: failure.DiagnosticCode,
+	}
+
+	err = app.models.Emails.RecordBounce(token, bounce)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	if bounce.Type == data.BounceHard {
+		err = app.models.Suppressions.Suppress(bounce.Recipient, data.SuppressionHardBounce, data.SourceBounce)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	app.logger.PrintInfo("recorded bounce", map[string]string{
+		"recipient": bounce.Recipient,
+		"type":      bounce.Type,
+		"status":    bounce.Status,
+	})
+
+	return bounce, nil
+}
+
+// startBouncePoller periodically processes the notifications delivered to a
+// local mbox file. The file is renamed before it is read, so that mail
+// delivered meanwhile goes to a fresh file instead of being lost.
+func (app *application) startBouncePoller(ctx context.Context) {
+	if app.config.bounce.mbox == "" {
+		return
+	}
+
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+			}
+		}()
+
+		ticker := time.NewTicker(app.config.bounce.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			err := app.pollBounces(app.config.bounce.mbox)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"mbox": app.config.bounce.mbox})
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (app *application) pollBounces(path string) error {
+	processing := path + ".processing"
+
+	// A file left behind by an interrupted run is finished first.
+	if _, err := os.Stat(processing); errors.Is(err, os.ErrNotExist) {
+		err = os.Rename(path, processing)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	f, err := os.Open(processing)
+	if err != nil {
+		return err
+	}
+
+	messages, err := mailer.ReadMbox(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, raw := range messages {
+		_, err := app.processBounce(raw)
+		if err != nil && !errors.Is(err, mailer.ErrNotDSN) {
+			// Keep the file so that the remaining messages are retried.
+			return err
+		}
+	}
+
+	return os.Remove(processing)
+}
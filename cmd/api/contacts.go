@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+func (app *application) listContactsHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.ContactFilters
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters.Address = app.readString(qs, "address", "")
+	filters.Name = app.readString(qs, "name", "")
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "id")
+	filters.SortSafelist = []string{"id", "address", "name", "created_at", "-id", "-address", "-name", "-created_at"}
+
+	if data.ValidateFilters(v, filters.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	contacts, metadata, err := app.models.Contacts.GetAll(filters)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"contacts": contacts, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) createContactHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Address    string       `json:"address"`
+		Name       string       `json:"name"`
+		Attributes data.JSONMap `json:"attributes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	c := &data.Contact{
+		Address:    input.Address,
+		Name:       input.Name,
+		Attributes: input.Attributes,
+	}
+
+	v := validator.New()
+
+	if data.ValidateContact(v, c); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Contacts.Insert(c)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateContact):
+			v.AddError("address", "a contact with this address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/contacts/%d", c.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"contact": c}, headers)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) showContactHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	c, err := app.models.Contacts.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"contact": c}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// updateContactHandler applies a partial update. attributes replaces the
+// contact's attributes as a whole.
+func (app *application) updateContactHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	c, err := app.models.Contacts.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Address    *string       `json:"address"`
+		Name       *string       `json:"name"`
+		Attributes *data.JSONMap `json:"attributes"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Address != nil {
+		c.Address = *input.Address
+	}
+	if input.Name != nil {
+		c.Name = *input.Name
+	}
+	if input.Attributes != nil {
+		c.Attributes = *input.Attributes
+	}
+
+	v := validator.New()
+
+	if data.ValidateContact(v, c); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Contacts.Update(c)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateContact):
+			v.AddError("address", "a contact with this address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"contact": c}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) deleteContactHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Contacts.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "contact successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+type contextKey string
+
+const (
+	apiKeyContextKey      = contextKey("apiKey")
+	authErrorContextKey   = contextKey("authError")
+	sendRequestContextKey = contextKey("sendRequest")
+)
+
+func (app *application) contextSetAPIKey(r *http.Request, key *data.APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), apiKeyContextKey, key)
+	return r.WithContext(ctx)
+}
+
+// contextGetAPIKey returns the key the request was authenticated with, or nil
+// for anonymous requests.
+func (app *application) contextGetAPIKey(r *http.Request) *data.APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey).(*data.APIKey)
+	return key
+}
+
+func (app *application) contextSetAuthError(r *http.Request, err error) *http.Request {
+	ctx := context.WithValue(r.Context(), authErrorContextKey, err)
+	return r.WithContext(ctx)
+}
+
+// contextGetAuthError returns why the API key in the request could not be
+// resolved, or nil when there was none or it was valid.
+func (app *application) contextGetAuthError(r *http.Request) error {
+	err, _ := r.Context().Value(authErrorContextKey).(error)
+	return err
+}
+
+func (app *application) contextSetSendRequest(r *http.Request, req *sendRequest) *http.Request {
+	ctx := context.WithValue(r.Context(), sendRequestContextKey, req)
+	return r.WithContext(ctx)
+}
+
+// contextGetSendRequest returns the send request already read by the
+// idempotent middleware, or nil when the handler has to read it itself.
+func (app *application) contextGetSendRequest(r *http.Request) *sendRequest {
+	req, _ := r.Context().Value(sendRequestContextKey).(*sendRequest)
+	return req
+}
@@ -6,8 +6,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mayura-andrew/email-client/internal/validator"
 )
 
 type envelop map[string]interface{}
@@ -22,8 +27,18 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+func (app *application) readRouteIDParam(r *http.Request, name string) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+	return id, nil
+}
+
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelop, headers http.Header) error {
-	
+
 	js, err := json.Marshal(data)
 	if err != nil {
 		return err
@@ -43,9 +58,16 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 }
 
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
-	maxBytes := 1_048_576
+	return app.readJSONLimit(w, r, dst, 1_048_576)
+}
+
+func (app *application) readJSONLimit(w http.ResponseWriter, r *http.Request, dst interface{}, maxBytes int) error {
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
-	dec := json.NewDecoder(r.Body)
+	return app.decodeJSON(r.Body, dst, maxBytes)
+}
+
+func (app *application) decodeJSON(body io.Reader, dst interface{}, maxBytes int) error {
+	dec := json.NewDecoder(body)
 	dec.DisallowUnknownFields()
 
 	err := dec.Decode(dst)
@@ -93,3 +115,89 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 	}
 	return nil
 }
+
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+	return i
+}
+
+func (app *application) readBool(qs url.Values, key string, v *validator.Validator) *bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return nil
+	}
+	return &b
+}
+
+// readTime accepts either a full RFC 3339 timestamp or a plain date, which is
+// taken as midnight UTC.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+
+	v.AddError(key, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+	return time.Time{}
+}
+
+// readUntil reads the upper bound of a time range. A date on its own covers
+// the whole day, so it is moved to midnight at the start of the next one.
+func (app *application) readUntil(qs url.Values, key string, v *validator.Validator) time.Time {
+	t := app.readTime(qs, key, v)
+
+	if _, err := time.Parse(time.DateOnly, qs.Get(key)); err == nil {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t
+}
+
+// mergeData combines the email-wide template data with a recipient's own
+// variables; the recipient's values win.
+func mergeData(base, override map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+
+	return merged
+}
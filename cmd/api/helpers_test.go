@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+func TestReadSinceUntil(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		value string
+		since time.Time
+		until time.Time
+	}{
+		{"2024-05-14", time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"2024-12-31", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-05-14T09:30:00Z", time.Date(2024, 5, 14, 9, 30, 0, 0, time.UTC), time.Date(2024, 5, 14, 9, 30, 0, 0, time.UTC)},
+		{"", time.Time{}, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		qs := url.Values{"since": {tt.value}, "until": {tt.value}}
+
+		if got := app.readTime(qs, "since", v); !got.Equal(tt.since) {
+			t.Errorf("since=%s read as %v, want %v", tt.value, got, tt.since)
+		}
+		if got := app.readUntil(qs, "until", v); !got.Equal(tt.until) {
+			t.Errorf("until=%s read as %v, want %v", tt.value, got, tt.until)
+		}
+		if !v.Valid() {
+			t.Errorf("%q: errors = %v", tt.value, v.Errors)
+		}
+	}
+
+	v := validator.New()
+	if got := app.readUntil(url.Values{"until": {"14/05/2024"}}, "until", v); !got.IsZero() || v.Valid() {
+		t.Errorf("until=14/05/2024 read as %v with errors %v, want an error", got, v.Errors)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// idempotent makes the send handler safe to retry. A request carrying an
+// Idempotency-Key header is stored with a fingerprint of the parsed request
+// and the response it got; replaying the same key within the configured
+// window returns that response instead of running next again. Server errors
+// are not stored, so they can be retried. The parsed request is handed on to
+// next through the request context.
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > 255 {
+			app.badRequestResponse(w, r, errors.New("Idempotency-Key must not be more than 255 bytes long"))
+			return
+		}
+
+		var req sendRequest
+
+		err := app.readSendRequest(w, r, &req)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		r = app.contextSetSendRequest(r, &req)
+
+		hash, err := req.fingerprint()
+		if err != nil {
+			app.serverErrorRespone(w, r, err)
+			return
+		}
+		apiKeyID := app.contextGetAPIKey(r).ID
+
+		record, err := app.models.Idempotency.Reserve(apiKeyID, key, hash, app.config.idempotency.window)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			default:
+				app.serverErrorRespone(w, r, err)
+			}
+			return
+		}
+
+		if record != nil {
+			app.replay(w, r, record, hash)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+
+		defer func() {
+			if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+				err := app.models.Idempotency.Release(apiKeyID, key)
+				if err != nil {
+					app.logError(r, err)
+				}
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		err = app.models.Idempotency.Complete(&data.IdempotencyRecord{
+			APIKeyID: apiKeyID,
+			Key:      key,
+			Status:   rec.status,
+			Location: rec.Header().Get("Location"),
+			Response: rec.body.Bytes(),
+		})
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+}
+
+// fingerprint hashes the parsed request, so that the same request sent again
+// hashes the same however it was encoded: as JSON or as multipart/form-data
+// with a new boundary. Attachments count by their metadata and the SHA-256 of
+// their content.
+func (req *sendRequest) fingerprint() ([]byte, error) {
+	type attachmentDigest struct {
+		Filename    string `json:"filename"`
+		ContentType string `json:"content_type"`
+		ContentID   string `json:"content_id"`
+		SHA256      []byte `json:"sha256"`
+	}
+
+	fields := *req
+	fields.Attachments = nil
+
+	digests := make([]attachmentDigest, 0, len(req.Attachments))
+	for _, a := range req.Attachments {
+		sum := sha256.Sum256(a.Content)
+		digests = append(digests, attachmentDigest{
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			ContentID:   a.ContentID,
+			SHA256:      sum[:],
+		})
+	}
+
+	js, err := json.Marshal(struct {
+		Request     sendRequest        `json:"request"`
+		Attachments []attachmentDigest `json:"attachments"`
+	}{fields, digests})
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(js)
+	return sum[:], nil
+}
+
+// replay answers a repeated request from its stored record.
+func (app *application) replay(w http.ResponseWriter, r *http.Request, record *data.IdempotencyRecord, hash []byte) {
+	if !bytes.Equal(record.RequestHash, hash) {
+		message := "the Idempotency-Key has already been used with a different request"
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+		return
+	}
+
+	if record.Status == 0 {
+		message := "a request with this Idempotency-Key is still being processed"
+		app.errorResponse(w, r, http.StatusConflict, message)
+		return
+	}
+
+	if record.Location != "" {
+		w.Header().Set("Location", record.Location)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Response)
+}
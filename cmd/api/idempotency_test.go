@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testPayload = `{"sender": "scholarx@sefglobal.org", "recipients": ["mentee@example.com"], "subject": "Welcome", "body": "Hello",
+	"template_data": {"cohort": "2024", "programme": "ScholarX"}}`
+
+func multipartSend(t *testing.T, boundary, payload string, files map[string]string) *http.Request {
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+	if err := mw.SetBoundary(boundary); err != nil {
+		t.Fatal(err)
+	}
+
+	mw.WriteField("payload", payload)
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("attachments", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/send", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func fingerprintOf(t *testing.T, r *http.Request) []byte {
+	var req sendRequest
+
+	app := &application{}
+	if err := app.readSendRequest(httptest.NewRecorder(), r, &req); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := req.fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestSendRequestFingerprint(t *testing.T) {
+	files := map[string]string{"schedule.txt": "week 1: kickoff"}
+
+	original := fingerprintOf(t, multipartSend(t, "boundary-one", testPayload, files))
+
+	if retry := fingerprintOf(t, multipartSend(t, "boundary-two", testPayload, files)); !bytes.Equal(original, retry) {
+		t.Error("a retry with a new multipart boundary has a different fingerprint")
+	}
+
+	reordered := `{"template_data": {"programme": "ScholarX", "cohort": "2024"}, "body": "Hello", "subject": "Welcome",
+		"recipients": ["mentee@example.com"], "sender": "scholarx@sefglobal.org"}`
+	if retry := fingerprintOf(t, multipartSend(t, "boundary-three", reordered, files)); !bytes.Equal(original, retry) {
+		t.Error("reordering the JSON fields changes the fingerprint")
+	}
+
+	asJSON := strings.TrimSuffix(testPayload, "}") + `, "attachments": [{"filename": "schedule.txt", "content_type": "application/octet-stream",
+		"content": "` + base64.StdEncoding.EncodeToString([]byte("week 1: kickoff")) + `"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/send", strings.NewReader(asJSON))
+	r.Header.Set("Content-Type", "application/json")
+	if retry := fingerprintOf(t, r); !bytes.Equal(original, retry) {
+		t.Error("the same request sent as JSON has a different fingerprint")
+	}
+
+	changed := map[string]string{"schedule.txt": "week 1: orientation"}
+	if other := fingerprintOf(t, multipartSend(t, "boundary-one", testPayload, changed)); bytes.Equal(original, other) {
+		t.Error("changing an attachment's content keeps the fingerprint")
+	}
+
+	renamed := map[string]string{"timetable.txt": "week 1: kickoff"}
+	if other := fingerprintOf(t, multipartSend(t, "boundary-one", testPayload, renamed)); bytes.Equal(original, other) {
+		t.Error("renaming an attachment keeps the fingerprint")
+	}
+
+	otherSubject := strings.Replace(testPayload, "Welcome", "Welcome!", 1)
+	if other := fingerprintOf(t, multipartSend(t, "boundary-one", otherSubject, files)); bytes.Equal(original, other) {
+		t.Error("changing the subject keeps the fingerprint")
+	}
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+// importBatchSize is how many rows are stored at a time, and so how often
+// the progress of an import is updated.
+const importBatchSize = 500
+
+// errImportInterrupted fails an import that was still running when the
+// server shut down.
+var errImportInterrupted = errors.New("interrupted by a shutdown")
+
+// Headers recognised as the address and name columns when the request
+// doesn't name them. Google Forms calls the collected address "Email
+// Address".
+var (
+	addressHeaders = []string{"email", "email address", "e-mail", "e-mail address", "address"}
+	nameHeaders    = []string{"name", "full name", "your name"}
+)
+
+// importMapping says which columns of a CSV file hold what. name is -1 when
+// there is no name column.
+type importMapping struct {
+	address    int
+	name       int
+	attributes map[int]string
+}
+
+// newImportMapping matches the columns against the header row. Column names
+// are compared case-insensitively. Without an explicit attributes mapping,
+// every other column becomes an attribute named after its header.
+func newImportMapping(v *validator.Validator, header []string, addressColumn, nameColumn string, attributes map[string]string) *importMapping {
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if _, ok := index[h]; !ok {
+			index[h] = i
+		}
+	}
+
+	find := func(column string, defaults []string) int {
+		if column != "" {
+			if i, ok := index[strings.ToLower(strings.TrimSpace(column))]; ok {
+				return i
+			}
+			return -1
+		}
+		for _, d := range defaults {
+			if i, ok := index[d]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+
+	m := &importMapping{
+		address:    find(addressColumn, addressHeaders),
+		name:       find(nameColumn, nameHeaders),
+		attributes: make(map[int]string),
+	}
+
+	switch {
+	case m.address < 0 && addressColumn != "":
+		v.AddError("address_column", fmt.Sprintf("column %q does not exist", addressColumn))
+	case m.address < 0:
+		v.AddError("address_column", "must be provided, no column is named "+strings.Join(addressHeaders, ", "))
+	}
+
+	if m.name < 0 && nameColumn != "" {
+		v.AddError("name_column", fmt.Sprintf("column %q does not exist", nameColumn))
+	}
+
+	if attributes != nil {
+		for column, attribute := range attributes {
+			i, ok := index[strings.ToLower(strings.TrimSpace(column))]
+			switch {
+			case !ok:
+				v.AddError("attributes", fmt.Sprintf("column %q does not exist", column))
+			case attribute == "":
+				v.AddError("attributes", fmt.Sprintf("column %q must be mapped to an attribute name", column))
+			default:
+				m.attributes[i] = attribute
+			}
+		}
+		return m
+	}
+
+	for i, h := range header {
+		if i == m.address || i == m.name {
+			continue
+		}
+		if key := attributeKey(h); key != "" {
+			m.attributes[i] = key
+		}
+	}
+
+	return m
+}
+
+// attributeKey turns a header such as "What is your university?" into
+// what_is_your_university.
+func attributeKey(header string) string {
+	var b strings.Builder
+
+	separate := false
+
+	for _, r := range strings.ToLower(header) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			separate = true
+			continue
+		}
+		if separate && b.Len() > 0 {
+			b.WriteByte('_')
+		}
+		separate = false
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// contact returns the contact held by a row.
+func (m *importMapping) contact(record []string) *data.Contact {
+	field := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	c := &data.Contact{
+		Address:    field(m.address),
+		Name:       field(m.name),
+		Attributes: make(data.JSONMap),
+	}
+
+	for i, attribute := range m.attributes {
+		if value := field(i); value != "" {
+			c.Attributes[attribute] = value
+		}
+	}
+
+	return c
+}
+
+// createImportHandler stores an uploaded text/csv body in a temporary file
+// and imports its rows in the background. The header row is checked before
+// the request returns, so a file without an address column is rejected
+// right away.
+func (app *application) createImportHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "text/csv" {
+		app.badRequestResponse(w, r, errors.New("body must be a text/csv file"))
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	listID := int64(app.readInt(qs, "list_id", 0, v))
+	addressColumn := app.readString(qs, "address_column", "")
+	nameColumn := app.readString(qs, "name_column", "")
+
+	var attributes map[string]string
+	if s := qs.Get("attributes"); s != "" {
+		err := json.Unmarshal([]byte(s), &attributes)
+		if err != nil {
+			v.AddError("attributes", "must be a JSON object mapping column names to attribute names")
+		}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if listID != 0 {
+		_, err := app.models.Lists.Get(listID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("list_id", "does not exist")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorRespone(w, r, err)
+			}
+			return
+		}
+	}
+
+	f, err := os.CreateTemp(app.config.imports.dir, "import-*.csv")
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	started := false
+	defer func() {
+		if !started {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	size, err := io.Copy(f, http.MaxBytesReader(w, r.Body, app.config.imports.maxBytes))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+			return
+		}
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	// Spreadsheet exports often start with a byte order mark.
+	br := bufio.NewReader(f)
+	var offset int64
+	if bom, _ := br.Peek(3); string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3)
+		offset = 3
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("body must contain a header row")
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	mapping := newImportMapping(v, header, addressColumn, nameColumn, attributes)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	imp := &data.ContactImport{
+		ListID: listID,
+		Status: data.ImportQueued,
+		Size:   size,
+	}
+
+	err = app.models.Imports.Insert(imp)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	started = true
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			f.Close()
+			os.Remove(f.Name())
+		}()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+			}
+		}()
+
+		app.runImport(app.background, imp, cr, offset, mapping)
+	}()
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/imports/%d", imp.ID))
+
+	err = app.writeJSON(w, http.StatusAccepted, envelop{"import": imp}, headers)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// runImport processes the rows after the header and records the outcome of
+// the import. Once ctx is cancelled it stops after the current batch and
+// fails the import.
+func (app *application) runImport(ctx context.Context, imp *data.ContactImport, cr *csv.Reader, offset int64, mapping *importMapping) {
+	err := app.importRows(ctx, imp, cr, offset, mapping)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"import": fmt.Sprint(imp.ID)})
+
+		imp.Status = data.ImportFailed
+		imp.Error = err.Error()
+
+		err = app.models.Imports.Progress(imp, nil)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"import": fmt.Sprint(imp.ID)})
+		}
+		return
+	}
+
+	app.logger.PrintInfo("imported contacts", map[string]string{
+		"import":   fmt.Sprint(imp.ID),
+		"rows":     fmt.Sprint(imp.Rows),
+		"accepted": fmt.Sprint(imp.Accepted),
+		"skipped":  fmt.Sprint(imp.Skipped),
+		"invalid":  fmt.Sprint(imp.Invalid),
+	})
+}
+
+// importRows reads the rows in batches of importBatchSize. Valid rows are
+// upserted as contacts, and added to the import's list. Later rows with an
+// address that was already seen are skipped. Rows are numbered by the line
+// they start on, so the header is row 1.
+func (app *application) importRows(ctx context.Context, imp *data.ContactImport, cr *csv.Reader, offset int64, mapping *importMapping) error {
+	var (
+		batch  []*data.Contact
+		report []data.ImportRow
+	)
+
+	flush := func() error {
+		if len(batch) > 0 {
+			added, err := app.models.Contacts.Upsert(batch, imp.ListID)
+			if err != nil {
+				return err
+			}
+			imp.Added += added
+		}
+
+		imp.BytesProcessed = offset + cr.InputOffset()
+
+		err := app.models.Imports.Progress(imp, report)
+		if err != nil {
+			return err
+		}
+
+		batch, report = batch[:0], report[:0]
+		return nil
+	}
+
+	imp.Status = data.ImportRunning
+
+	err := flush()
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]int)
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		imp.Rows++
+
+		var (
+			parseErr *csv.ParseError
+			row      int
+		)
+
+		switch {
+		case errors.As(err, &parseErr):
+			row = parseErr.StartLine
+			report = append(report, data.ImportRow{Row: row, Status: data.RowInvalid, Reason: parseErr.Err.Error()})
+			imp.Invalid++
+		case err != nil:
+			return err
+		default:
+			row, _ = cr.FieldPos(0)
+			c := mapping.contact(record)
+			address := strings.ToLower(c.Address)
+
+			v := validator.New()
+			data.ValidateContact(v, c)
+
+			switch {
+			case !v.Valid():
+				report = append(report, data.ImportRow{Row: row, Address: c.Address, Status: data.RowInvalid, Reason: contactError(v)})
+				imp.Invalid++
+			case seen[address] != 0:
+				report = append(report, data.ImportRow{Row: row, Address: c.Address, Status: data.RowSkipped, Reason: fmt.Sprintf("duplicate of row %d", seen[address])})
+				imp.Skipped++
+			default:
+				seen[address] = row
+				batch = append(batch, c)
+				report = append(report, data.ImportRow{Row: row, Address: c.Address, Status: data.RowAccepted})
+				imp.Accepted++
+			}
+		}
+
+		if len(report) >= importBatchSize {
+			err = flush()
+			if err != nil {
+				return err
+			}
+
+			if ctx.Err() != nil {
+				return errImportInterrupted
+			}
+		}
+	}
+
+	imp.Status = data.ImportCompleted
+
+	return flush()
+}
+
+// contactError describes why a row failed ValidateContact, preferring the
+// address over the name.
+func contactError(v *validator.Validator) string {
+	for _, key := range []string{"address", "name"} {
+		if message, ok := v.Errors[key]; ok {
+			return key + " " + message
+		}
+	}
+	for key, message := range v.Errors {
+		return key + " " + message
+	}
+	return ""
+}
+
+func (app *application) showImportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	imp, err := app.models.Imports.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"import": imp}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// listImportRowsHandler returns the row-level report of an import, in row
+// order.
+func (app *application) listImportRowsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Imports.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	filters := data.ImportRowFilters{
+		Filters: data.Filters{Sort: "row_number", SortSafelist: []string{"row_number"}},
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters.Status = app.readString(qs, "status", "")
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 100, v)
+
+	v.Check(filters.Status == "" || validator.PermittedValue(filters.Status, data.ImportRowStatuses...), "status", "must be accepted, skipped or invalid")
+
+	if data.ValidateFilters(v, filters.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rows, metadata, err := app.models.Imports.GetRows(id, filters)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"rows": rows, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// failInterruptedImports runs at startup. Uploads don't survive a restart,
+// so imports that were cut short can't be resumed.
+func (app *application) failInterruptedImports() {
+	n, err := app.models.Imports.FailInterrupted()
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+
+	if n > 0 {
+		app.logger.PrintInfo("failed interrupted contact imports", map[string]string{
+			"count": fmt.Sprint(n),
+		})
+	}
+}
@@ -36,13 +36,61 @@ func (app *application) rootHandler(w http.ResponseWriter, r *http.Request) {
 		<div class="container">
 			<h1>Welcome to Our API!</h1>
 			<h2>API Endpoints</h2>
+			<p>Every endpoint apart from the healthcheck, this page, tracking, unsubscribe and preference links requires an <code>Authorization: Bearer &lt;key&gt;</code> header with the <code>send</code>, <code>read</code> or <code>admin</code> scope.</p>
 			<p><strong>GET /api/v1/healthcheck:</strong> Check the health of the application.</p>
 			<p><strong>GET /debug/vars:</strong> Get debug variables.</p>
 			<p><strong>GET /:</strong> Root endpoint.</p>
-			<p><strong>POST /api/v1/send:</strong> Send an email.</p>
-			<p><strong>GET /api/v1/track:</strong> Track an email.</p>
+			<p><strong>GET /preferences/:token:</strong> Preference center where a recipient picks the topics they receive.</p>
+			<p><strong>POST /preferences/:token:</strong> Save a recipient's topic preferences.</p>
+			<p><strong>POST /api/v1/send:</strong> Queue an email for delivery. Send an <code>Idempotency-Key</code> header to make retries safe.</p>
+			<p><strong>GET /api/v1/jobs/:id:</strong> Get the delivery progress of a queued email.</p>
+			<p><strong>GET /api/v1/emails/:id:</strong> Get an email and the delivery status of every recipient.</p>
+			<p><strong>GET /api/v1/emails/:id/recipients/:rid:</strong> Get the delivery status and clicks of a single recipient.</p>
+			<p><strong>GET /api/v1/t/open/:token:</strong> Tracking pixel; records an email open.</p>
+			<p><strong>GET /api/v1/t/click/:token:</strong> Records a click on a tracked link and redirects to it.</p>
+			<p><strong>GET /api/v1/unsubscribe/:token:</strong> Unsubscribe confirmation page.</p>
+			<p><strong>POST /api/v1/unsubscribe/:token:</strong> Unsubscribe, including RFC 8058 one-click requests.</p>
+			<p><strong>GET /api/v1/emails/:id/stats:</strong> Get the engagement statistics of an email.</p>
+			<p><strong>GET /api/v1/stats:</strong> Get engagement statistics across emails.</p>
 			<p><strong>GET /api/v1/sent:</strong> Retrieve all sent emails.</p>
-			<p><strong>GET /api/v1/recipients/:email:</strong> Get information about a specific email recipient.</p>
+			<p><strong>GET /api/v1/exports/sent:</strong> Download the sent history as CSV or JSON Lines.</p>
+			<p><strong>POST /api/v1/bounces:</strong> Record a bounce from a raw RFC 3464 delivery status notification.</p>
+			<p><strong>GET /api/v1/scheduled:</strong> List emails waiting for their send_at time.</p>
+			<p><strong>PATCH /api/v1/scheduled/:id:</strong> Move the send_at time of a scheduled email.</p>
+			<p><strong>DELETE /api/v1/scheduled/:id:</strong> Cancel a scheduled email before it goes out.</p>
+			<p><strong>POST /api/v1/templates:</strong> Create a named email template (admin).</p>
+			<p><strong>GET /api/v1/templates:</strong> List email templates.</p>
+			<p><strong>GET /api/v1/templates/:id:</strong> Get the current version of a template.</p>
+			<p><strong>GET /api/v1/templates/:id/versions:</strong> Get the version history of a template.</p>
+			<p><strong>PATCH /api/v1/templates/:id:</strong> Update a template, creating a new version (admin).</p>
+			<p><strong>DELETE /api/v1/templates/:id:</strong> Delete an unused template (admin).</p>
+			<p><strong>GET /api/v1/contacts:</strong> List contacts.</p>
+			<p><strong>POST /api/v1/contacts:</strong> Create a contact with a name and custom attributes (admin).</p>
+			<p><strong>GET /api/v1/contacts/:id:</strong> Get a contact.</p>
+			<p><strong>PATCH /api/v1/contacts/:id:</strong> Update a contact (admin).</p>
+			<p><strong>DELETE /api/v1/contacts/:id:</strong> Delete a contact (admin).</p>
+			<p><strong>GET /api/v1/lists:</strong> List audience lists with their member counts.</p>
+			<p><strong>POST /api/v1/lists:</strong> Create an audience list (admin).</p>
+			<p><strong>GET /api/v1/lists/:id:</strong> Get an audience list.</p>
+			<p><strong>PATCH /api/v1/lists/:id:</strong> Rename or describe a list (admin).</p>
+			<p><strong>DELETE /api/v1/lists/:id:</strong> Delete a list, keeping its contacts (admin).</p>
+			<p><strong>GET /api/v1/lists/:id/members:</strong> List the contacts of a list.</p>
+			<p><strong>POST /api/v1/lists/:id/members:</strong> Add many contacts to a list, creating new ones (admin).</p>
+			<p><strong>DELETE /api/v1/lists/:id/members/:contact_id:</strong> Remove a contact from a list (admin).</p>
+			<p><strong>POST /api/v1/imports:</strong> Import contacts from a CSV file in the background (admin).</p>
+			<p><strong>GET /api/v1/imports/:id:</strong> Get the progress of a contact import (admin).</p>
+			<p><strong>GET /api/v1/imports/:id/rows:</strong> Get the row-level report of a contact import (admin).</p>
+			<p><strong>GET /api/v1/topics:</strong> List subscription topics.</p>
+			<p><strong>POST /api/v1/topics:</strong> Create a subscription topic (admin).</p>
+			<p><strong>DELETE /api/v1/topics/:name:</strong> Delete a topic and its preferences (admin).</p>
+			<p><strong>GET /api/v1/suppressions:</strong> List suppressed addresses (admin).</p>
+			<p><strong>POST /api/v1/suppressions:</strong> Suppress an address (admin).</p>
+			<p><strong>POST /api/v1/suppressions/import:</strong> Suppress many addresses from JSON or CSV (admin).</p>
+			<p><strong>DELETE /api/v1/suppressions/:address:</strong> Remove an address from the suppression list (admin).</p>
+			<p><strong>POST /api/v1/keys:</strong> Create an API key (admin).</p>
+			<p><strong>GET /api/v1/keys:</strong> List API keys (admin).</p>
+			<p><strong>DELETE /api/v1/keys/:id:</strong> Revoke an API key (admin).</p>
+			<p><strong>GET /api/v1/recipients/:email:</strong> Get everything sent to an address, with sent, delivered, pending, failed, skipped, opened and open rate totals.</p>
 		</div>
 	</body>
 	</html>
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+// maxListIDs bounds list_ids in a send request.
+const maxListIDs = 100
+
+func (app *application) listListsHandler(w http.ResponseWriter, r *http.Request) {
+	lists, err := app.models.Lists.GetAll()
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"lists": lists}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) createListHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	l := &data.List{
+		Name:        input.Name,
+		Description: input.Description,
+	}
+
+	v := validator.New()
+
+	if data.ValidateList(v, l); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Insert(l)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateListName):
+			v.AddError("name", "a list with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/lists/%d", l.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"list": l}, headers)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// getList looks up the list named by the id route parameter and sends a
+// response itself if that fails.
+func (app *application) getList(w http.ResponseWriter, r *http.Request) (*data.List, bool) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	l, err := app.models.Lists.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return nil, false
+	}
+
+	return l, true
+}
+
+func (app *application) showListHandler(w http.ResponseWriter, r *http.Request) {
+	l, ok := app.getList(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelop{"list": l}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	l, ok := app.getList(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		l.Name = *input.Name
+	}
+	if input.Description != nil {
+		l.Description = *input.Description
+	}
+
+	v := validator.New()
+
+	if data.ValidateList(v, l); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Update(l)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateListName):
+			v.AddError("name", "a list with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"list": l}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Lists.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "list successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) listMembersHandler(w http.ResponseWriter, r *http.Request) {
+	l, ok := app.getList(w, r)
+	if !ok {
+		return
+	}
+
+	filters := data.ContactFilters{ListID: l.ID}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters.Address = app.readString(qs, "address", "")
+	filters.Name = app.readString(qs, "name", "")
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "id")
+	filters.SortSafelist = []string{"id", "address", "name", "created_at", "-id", "-address", "-name", "-created_at"}
+
+	if data.ValidateFilters(v, filters.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	contacts, metadata, err := app.models.Contacts.GetAll(filters)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"contacts": contacts, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// contactInput is a contact in a bulk add. Like a send request recipient,
+// it is either a plain address string or an object:
+// {"address": "alice@example.com", "name": "Alice", "attributes": {...}}.
+type contactInput struct {
+	Address    string       `json:"address"`
+	Name       string       `json:"name"`
+	Attributes data.JSONMap `json:"attributes"`
+}
+
+func (c *contactInput) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &c.Address)
+	}
+
+	var aux struct {
+		Address    string       `json:"address"`
+		Name       string       `json:"name"`
+		Attributes data.JSONMap `json:"attributes"`
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&aux); err != nil {
+		return err
+	}
+
+	*c = contactInput(aux)
+	return nil
+}
+
+// addMembersHandler adds many contacts to a list at once, creating the ones
+// that don't exist yet.
+func (app *application) addMembersHandler(w http.ResponseWriter, r *http.Request) {
+	l, ok := app.getList(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Contacts []contactInput `json:"contacts"`
+	}
+
+	err := app.readJSONLimit(w, r, &input, 10<<20)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(len(input.Contacts) > 0, "contacts", "must be provided")
+	v.Check(len(input.Contacts) <= 100_000, "contacts", "must not contain more than 100000 contacts")
+
+	contacts := make([]*data.Contact, len(input.Contacts))
+
+	for i, in := range input.Contacts {
+		contacts[i] = &data.Contact{Address: in.Address, Name: in.Name, Attributes: in.Attributes}
+
+		cv := validator.New()
+		if data.ValidateContact(cv, contacts[i]); !cv.Valid() {
+			for key, message := range cv.Errors {
+				v.AddError("contacts", fmt.Sprintf("entry %d: %s %s", i+1, key, message))
+			}
+			break
+		}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	added, err := app.models.Contacts.Upsert(contacts, l.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"added": added}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) removeMemberHandler(w http.ResponseWriter, r *http.Request) {
+	listID, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	contactID, err := app.readRouteIDParam(r, "contact_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Lists.RemoveMember(listID, contactID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "contact successfully removed from the list"}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// expandLists adds the members of the lists to the email's to recipients.
+// Every address is sent to once: members that are already among the
+// recipients, cc or bcc, or in several of the lists, are skipped, comparing
+// addresses case-insensitively, and an explicitly given address keeps its
+// own merge fields.
+func (app *application) expandLists(v *validator.Validator, email *data.Email, ids []int64) error {
+	if len(ids) > maxListIDs {
+		v.AddError("list_ids", fmt.Sprintf("must not contain more than %d lists", maxListIDs))
+		return nil
+	}
+
+	missing, err := app.models.Lists.Missing(ids)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		v.AddError("list_ids", fmt.Sprintf("list %d does not exist", missing[0]))
+		return nil
+	}
+
+	members, err := app.models.Lists.Addresses(ids)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, list := range [][]data.Address{email.Recipients, email.CC, email.BCC} {
+		for _, a := range list {
+			seen[strings.ToLower(a.Email)] = true
+		}
+	}
+
+	for _, a := range members {
+		if seen[a.Email] {
+			continue
+		}
+		seen[a.Email] = true
+		email.Recipients = append(email.Recipients, a)
+	}
+
+	v.Check(len(email.Recipients) > 0, "list_ids", "the lists have no members")
+
+	return nil
+}
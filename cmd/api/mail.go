@@ -3,100 +3,302 @@ package main
 import (
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"path"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/go-mail/mail/v2"
+	"github.com/julienschmidt/httprouter"
 	"github.com/mayura-andrew/email-client/internal/data"
 	"github.com/mayura-andrew/email-client/internal/mailer"
 	"github.com/mayura-andrew/email-client/internal/validator"
 )
 
-type Mailer struct {
-	dailer *mail.Dialer
-	sender string
-}
-
-type EmailStatus struct {
-	Sent     bool
-	Opened   bool
-	SentTime time.Time
+type sendRequest struct {
+	Sender       string            `json:"sender"`
+	Recipients   []data.Address    `json:"recipients"`
+	ListIDs      []int64           `json:"list_ids"`
+	CC           []data.Address    `json:"cc"`
+	BCC          []data.Address    `json:"bcc"`
+	ReplyTo      []string          `json:"reply_to"`
+	Subject      string            `json:"subject"`
+	Body         string            `json:"body"`
+	SendAt       *time.Time        `json:"send_at"`
+	Topic        string            `json:"topic"`
+	Template     string            `json:"template"`
+	TemplateData map[string]any    `json:"template_data"`
+	Attachments  []attachmentInput `json:"attachments"`
 }
 
 func (app *application) sendEmailHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse the request body
-	var req struct {
-		Sender     string   `json:"sender"`
-		Recipients []string `json:"recipients"`
-		Subject    string   `json:"subject"`
-		Body       string   `json:"body"`
-	}
+	// Parse the request body, unless the idempotent middleware already has
+	req := app.contextGetSendRequest(r)
+	if req == nil {
+		req = &sendRequest{}
 
-	err := app.readJSON(w, r, &req)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
-		return
+		err := app.readSendRequest(w, r, req)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
 	}
 
 	email := &data.Email{
 		Sender:     req.Sender,
 		Recipients: req.Recipients,
+		CC:         req.CC,
+		BCC:        req.BCC,
+		ReplyTo:    req.ReplyTo,
 		Subject:    req.Subject,
 		Body:       req.Body,
+		APIKeyID:   app.contextGetAPIKey(r).ID,
+		SendAt:     req.SendAt,
+
+		TemplateData: req.TemplateData,
+		Attachments:  toAttachments(req.Attachments),
 	}
 
 	v := validator.New()
 
+	if req.Template != "" {
+		tmpl, err := app.models.Templates.GetByName(req.Template)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("template", "does not exist")
+			default:
+				app.serverErrorRespone(w, r, err)
+				return
+			}
+		} else {
+			email.TemplateID = tmpl.ID
+			email.TemplateVersion = tmpl.Version
+			if email.Subject == "" {
+				email.Subject = tmpl.Subject
+			}
+		}
+	}
+
+	if req.Topic != "" {
+		topic, err := app.models.Topics.GetByName(req.Topic)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("topic", "does not exist")
+			default:
+				app.serverErrorRespone(w, r, err)
+				return
+			}
+		} else {
+			email.TopicID = topic.ID
+			email.Topic = topic.Name
+		}
+	}
+
+	if len(req.ListIDs) > 0 {
+		err := app.expandLists(v, email, req.ListIDs)
+		if err != nil {
+			app.serverErrorRespone(w, r, err)
+			return
+		}
+	}
+
 	if data.ValidateEmail(v, email); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	emailStatus, err := mailer.NewMail(app.models.Emails, app.config.smtp.host, app.config.smtp.port, app.config.smtp.username, app.config.smtp.password, app.config.smtp.sender, req.Subject, req.Recipients, req.Body)
+	tmpl, err := app.sendTemplate(email)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	content, err := mailer.ParseContent(email.Subject, email.Body)
+	if err != nil {
+		v.AddError("subject", "subject and body must be valid templates: "+err.Error())
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if validateVariables(v, email, tmpl.Variables(), content.Variables()); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	results, err := app.applySuppressions(email)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	if len(email.Recipients)+len(email.CC)+len(email.BCC) == 0 {
+		v.AddError("recipients", "every recipient is suppressed or opted out of the topic")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	job, err := app.models.Jobs.Enqueue(email)
 	if err != nil {
-		http.Error(w, "Failed to send email", http.StatusInternalServerError)
+		app.serverErrorRespone(w, r, err)
 		return
 	}
 
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/api/v1/emails/%d", email.ID))
 
-	err = app.writeJSON(w, http.StatusCreated, envelop{"status": emailStatus}, headers)
+	err = app.writeJSON(w, http.StatusAccepted, envelop{"job": job, "recipients": results}, headers)
 	if err != nil {
 		app.serverErrorRespone(w, r, err)
 	}
 }
 
-func (app *application) track(w http.ResponseWriter, r *http.Request) {
-	id, err := app.readIDParam(r)
-	fmt.Println(id)
+// sendTemplate returns the parsed template the email will be rendered with.
+func (app *application) sendTemplate(email *data.Email) (*mailer.Template, error) {
+	if email.TemplateID == 0 {
+		return app.mailer.DefaultTemplate()
+	}
+	return app.mailer.Template(email.TemplateID, email.TemplateVersion, app.models.Templates.GetVersion)
+}
+
+// maxMissingVariables caps how many recipients are named when template
+// variables are missing.
+const maxMissingVariables = 10
+
+// validateVariables checks that every recipient has a value for every
+// variable the template and the email's own subject and body use, either in
+// its own data or in template_data. The error names up to
+// maxMissingVariables offending recipients.
+func validateVariables(v *validator.Validator, email *data.Email, uses ...[]string) {
+	seen := make(map[string]bool)
+	var names []string
+
+	for _, vars := range uses {
+		for _, name := range vars {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var (
+		problems []string
+		more     int
+	)
+
+	for _, list := range [][]data.Address{email.Recipients, email.CC, email.BCC} {
+		for _, address := range list {
+			var missing []string
+
+			for _, name := range names {
+				if _, ok := address.Data[name]; ok {
+					continue
+				}
+				if _, ok := email.TemplateData[name]; ok {
+					continue
+				}
+				missing = append(missing, name)
+			}
+
+			if len(missing) == 0 {
+				continue
+			}
+
+			if len(problems) == maxMissingVariables {
+				more++
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s is missing template variables: %s", address.Email, strings.Join(missing, ", ")))
+		}
+	}
+
+	if len(problems) == 0 {
+		return
+	}
+
+	if more > 0 {
+		problems = append(problems, fmt.Sprintf("and %d more recipients", more))
+	}
+	v.AddError("recipients", strings.Join(problems, "; "))
+}
+
+func (app *application) showJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
 	if err != nil {
-		app.writeJSON(w, http.StatusBadRequest, envelop{"status": map[string]string{"error": "Missing id parameter"}}, nil)
+		app.notFoundResponse(w, r)
 		return
 	}
-	
-	log.Printf("Email opened: %d", id)
-	
-	err = mailer.UpdateEmailTracking(app.models.Emails, id)
+
+	job, err := app.models.Jobs.Get(id)
 	if err != nil {
-		log.Printf("Failed to update email tracking: %v", err)
-		app.writeJSON(w, http.StatusBadRequest, envelop{"status": map[string]string{"error": "Internal server error"}}, nil)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"job": job}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
 	}
-	redirectURL := "https://scholarx.sefglobal.org"
-	http.Redirect(w, r, redirectURL, http.StatusFound)
 }
 
-func (app *application) showEmailHandler(w http.ResponseWriter, r *http.Request) {
+func (app *application) getEmailHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
 
-	recipient := path.Base(r.URL.Path)
-	if recipient == "" {
+	email, err := app.models.Emails.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	recipients, err := app.models.Emails.GetRecipients(email.ID)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	email.Attachments, err = app.models.Attachments.GetForEmail(email.ID, false)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"email": email, "recipients": recipients}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) getRecipientHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
 		app.notFoundResponse(w, r)
 		return
-	} 
+	}
+
+	rid, err := app.readRouteIDParam(r, "rid")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
 
-	emails, err := app.models.Emails.GetAllSent()
+	recipient, err := app.models.Emails.GetRecipient(id, rid)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -107,12 +309,113 @@ func (app *application) showEmailHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if len(*emails) == 0 {
-		app.writeJSON(w, http.StatusOK, envelop{"emails": []string{}}, nil)		
+	clicks, err := app.models.Clicks.GetForRecipient(recipient.ID)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	events, err := app.models.Events.GetForRecipient(recipient.ID)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"recipient": recipient, "events": events, "clicks": clicks}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// redirectSunset is when the legacy /api/v1/redirect endpoint is removed.
+// Only emails sent before the tracking pixel link to it, and their opens
+// stop mattering after a few months.
+const redirectSunset = "Fri, 30 Apr 2027 00:00:00 GMT"
+
+// track records an open for the recipient identified by the token query
+// parameter or, in emails sent before tracking tokens, by its id. Every
+// request gets the same redirect, whether or not the recipient exists, so
+// the endpoint can't be used to probe for tokens.
+//
+// Deprecated: the endpoint is kept for emails sent before trackOpenHandler
+// existed and is removed after redirectSunset.
+func (app *application) track(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("token") {
+		app.recordOpen(r, r.URL.Query().Get("token"))
+	} else if id, err := app.readIDParam(r); err == nil {
+		err = app.models.Events.InsertOpenByRecipientID(id, app.openEvent(r))
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Sunset", redirectSunset)
+
+	redirectURL := "https://scholarx.sefglobal.org"
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// readSentFilters reads the filters and sort order shared by the sent
+// history listing and its export.
+func (app *application) readSentFilters(qs url.Values, v *validator.Validator) data.SentFilters {
+	var filters data.SentFilters
+
+	filters.Sender = app.readString(qs, "sender", "")
+	filters.Status = app.readString(qs, "status", "")
+	filters.Opened = app.readBool(qs, "opened", v)
+	filters.Since = app.readTime(qs, "since", v)
+	filters.Until = app.readUntil(qs, "until", v)
+
+	filters.Sort = app.readString(qs, "sort", "-sent_time")
+	filters.SortSafelist = []string{"sent_time", "opened_time", "recipient", "subject", "-sent_time", "-opened_time", "-recipient", "-subject"}
+
+	return filters
+}
+
+func (app *application) showEmailHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := app.readSentFilters(qs, v)
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	if data.ValidateSentFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	emails, metadata, err := app.models.Emails.GetAllSent(filters)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"emails": emails, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) showRecipientHandler(w http.ResponseWriter, r *http.Request) {
+	recipient := httprouter.ParamsFromContext(r.Context()).ByName("email")
+
+	v := validator.New()
+
+	if v.Check(validator.Matches(recipient, validator.EmailRx), "email", "must be a valid email address"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	history, totals, err := app.models.Emails.GetByRecipient(recipient)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelop{"emails": emails}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelop{"recipient": recipient, "emails": history, "totals": totals}, nil)
 	if err != nil {
 		app.serverErrorRespone(w, r, err)
 	}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+	"github.com/mayura-andrew/email-client/internal/mailer"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+func TestValidateVariables(t *testing.T) {
+	tmpl, err := mailer.ParseTemplate("{{.Data.cohort}}", "{{.Data.name}}", "<p>{{.Data.name}}</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		v := validator.New()
+		validateVariables(v, &data.Email{
+			Recipients:   []data.Address{{Email: "alice@example.com", Data: map[string]any{"name": "Alice"}}},
+			TemplateData: data.JSONMap{"cohort": "2024"},
+		}, tmpl.Variables())
+		if !v.Valid() {
+			t.Errorf("errors = %v, want none", v.Errors)
+		}
+	})
+
+	t.Run("every recipient", func(t *testing.T) {
+		v := validator.New()
+		validateVariables(v, &data.Email{
+			Recipients:   []data.Address{{Email: "alice@example.com"}, {Email: "bob@example.com", Data: map[string]any{"name": "Bob"}}},
+			CC:           []data.Address{{Email: "carol@example.com"}},
+			TemplateData: data.JSONMap{"cohort": "2024"},
+		}, tmpl.Variables())
+
+		want := "alice@example.com is missing template variables: name; carol@example.com is missing template variables: name"
+		if got := v.Errors["recipients"]; got != want {
+			t.Errorf("error = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("subject and body", func(t *testing.T) {
+		content, err := mailer.ParseContent("Hi {{.Data.first}}", "Your mentor is {{index .Data \"mentor\"}}. {{.Data.name}}")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v := validator.New()
+		validateVariables(v, &data.Email{
+			Recipients:   []data.Address{{Email: "alice@example.com", Data: map[string]any{"name": "Alice", "first": "Al"}}},
+			TemplateData: data.JSONMap{"cohort": "2024"},
+		}, tmpl.Variables(), content.Variables())
+
+		want := "alice@example.com is missing template variables: mentor"
+		if got := v.Errors["recipients"]; got != want {
+			t.Errorf("error = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("capped", func(t *testing.T) {
+		email := &data.Email{}
+		for i := 0; i < maxMissingVariables+3; i++ {
+			email.Recipients = append(email.Recipients, data.Address{Email: fmt.Sprintf("user%d@example.com", i)})
+		}
+
+		v := validator.New()
+		validateVariables(v, email, tmpl.Variables())
+
+		got := v.Errors["recipients"]
+		if n := strings.Count(got, "is missing template variables: cohort, name"); n != maxMissingVariables {
+			t.Errorf("names %d recipients, want %d: %q", n, maxMissingVariables, got)
+		}
+		if !strings.HasSuffix(got, "; and 3 more recipients") {
+			t.Errorf("error = %q, want it to count the other 3 recipients", got)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"expvar"
 	"flag"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -31,6 +33,7 @@ type config struct {
 	// API SERVER - configurations
 	port int
 	env  string
+	url  string
 
 	// SMTP - configurations
 	smtp struct {
@@ -39,6 +42,7 @@ type config struct {
 		username string
 		password string
 		sender   string
+		maxConns int
 	}
 
 	cors struct {
@@ -57,13 +61,53 @@ type config struct {
 		burst   int
 		enabled bool
 	}
+
+	queue struct {
+		workers      int
+		pollInterval time.Duration
+		lease        time.Duration
+	}
+
+	scheduler struct {
+		interval time.Duration
+	}
+
+	idempotency struct {
+		window time.Duration
+	}
+
+	bounce struct {
+		mbox         string
+		pollInterval time.Duration
+	}
+
+	imports struct {
+		dir      string
+		maxBytes int64
+	}
+
+	tracking struct {
+		secret      string
+		anonymizeIP bool
+	}
+
+	retry struct {
+		maxAttempts int
+		backoff     mailer.Backoff
+	}
 }
 
 type application struct {
-	config config
-	mailer mailer.Mailer
-	logger *jsonlog.Logger
-	models data.Models
+	config  config
+	mailer  mailer.Mailer
+	tracker *mailer.Tracker
+	logger  *jsonlog.Logger
+	models  data.Models
+	wg      sync.WaitGroup
+
+	// background is cancelled when the server shuts down. Work that handlers
+	// start in the background watches it and stops early.
+	background context.Context
 }
 
 func main() {
@@ -76,6 +120,7 @@ func main() {
 
 	flag.IntVar(&cfg.port, "port", 4000, "Email API Server Port")
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|statging|production)")
+	flag.StringVar(&cfg.url, "url", os.Getenv("URL"), "Public absolute base URL used in tracking, unsubscribe and preference links (required)")
 	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("DB_DSN"),
 		"PostgreSQL DSN")
 
@@ -87,6 +132,28 @@ func main() {
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
+	flag.IntVar(&cfg.queue.workers, "queue-workers", 10, "Number of background delivery workers")
+	flag.DurationVar(&cfg.queue.pollInterval, "queue-poll-interval", time.Second, "How often idle workers poll the delivery queue")
+	flag.DurationVar(&cfg.queue.lease, "queue-lease", 5*time.Minute, "How long a worker holds a claimed recipient before it is retried")
+
+	flag.DurationVar(&cfg.scheduler.interval, "scheduler-interval", 10*time.Second, "How often scheduled emails are checked for being due")
+
+	flag.StringVar(&cfg.bounce.mbox, "bounce-mbox", "", "Local mbox file receiving delivery status notifications (disabled when empty)")
+	flag.DurationVar(&cfg.bounce.pollInterval, "bounce-poll-interval", time.Minute, "How often the bounce mbox is processed")
+
+	flag.StringVar(&cfg.tracking.secret, "tracking-secret", os.Getenv("TRACKING_SECRET"), "Secret used to sign click, unsubscribe and preference links (required, at least 16 bytes)")
+	flag.BoolVar(&cfg.tracking.anonymizeIP, "tracking-anonymize-ip", true, "Store open events with the host part of the IP address zeroed")
+
+	flag.StringVar(&cfg.imports.dir, "import-dir", "", "Directory for CSV uploads while they are imported (defaults to the system temporary directory)")
+	flag.Int64Var(&cfg.imports.maxBytes, "import-max-bytes", 50<<20, "Largest CSV file accepted by the contact import")
+
+	flag.DurationVar(&cfg.idempotency.window, "idempotency-window", 24*time.Hour, "How long a send request's Idempotency-Key is remembered")
+
+	flag.IntVar(&cfg.retry.maxAttempts, "retry-max-attempts", 5, "Delivery attempts per recipient before it is marked failed")
+	flag.DurationVar(&cfg.retry.backoff.Base, "retry-base-delay", 30*time.Second, "Delay before the first retry, doubled on every attempt")
+	flag.DurationVar(&cfg.retry.backoff.Max, "retry-max-delay", time.Hour, "Upper bound for the delay between retries")
+	flag.Float64Var(&cfg.retry.backoff.Jitter, "retry-jitter", 0.2, "Random spread applied to each retry delay (0-1)")
+
 	envVarValue := os.Getenv("SMTPPORT")
 
 	if envVarValue == "" {
@@ -109,8 +176,9 @@ func main() {
 	flag.IntVar(&cfg.smtp.port, "SMTPPORT", intValue, "SMTP port")
 	flag.StringVar(&cfg.smtp.username, "SMTPUSERNAME", os.Getenv("SMTPUSERNAME"), "SMTP username")
 	flag.StringVar(&cfg.smtp.password, "SMTPPASS",
-	 os.Getenv("SMTPPASS"), "SMTP password")
+		os.Getenv("SMTPPASS"), "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "SMTPSENDER", smtpSender, "SMTP sender")
+	flag.IntVar(&cfg.smtp.maxConns, "smtp-max-conns", 5, "Maximum number of open SMTP connections")
 
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
 		cfg.cors.trustedOrigns = strings.Fields(val)
@@ -118,6 +186,7 @@ func main() {
 	})
 
 	displayVersion := flag.Bool("version", false, "Display version and exit")
+	createAdminKey := flag.String("create-admin-key", "", "Create an admin API key with the given name, print it and exit")
 	flag.Parse()
 
 	if *displayVersion {
@@ -146,12 +215,37 @@ func main() {
 
 	logger.PrintInfo("database connection pool established", map[string]string{})
 
+	if *createAdminKey != "" {
+		key, err := data.NewModel(db).APIKeys.New(*createAdminKey, []string{data.ScopeAdmin})
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		fmt.Printf("API key: \t%s\n", key.Plaintext)
+		os.Exit(0)
+	}
+
+	// Unsubscribe, preference and click links are signed with the tracking
+	// secret and point at the public URL. Both must stay the same across
+	// restarts, or links in emails already sent stop working.
+	if len(cfg.tracking.secret) < 16 {
+		logger.PrintFatal(errors.New("-tracking-secret (or TRACKING_SECRET) must be set to at least 16 bytes"), nil)
+	}
+
+	publicURL, err := url.Parse(cfg.url)
+	if err != nil || (publicURL.Scheme != "http" && publicURL.Scheme != "https") || publicURL.Host == "" {
+		logger.PrintFatal(errors.New("-url (or URL) must be an absolute http or https URL"), map[string]string{"url": cfg.url})
+	}
+
+	tracker := mailer.NewTracker(cfg.url, []byte(cfg.tracking.secret))
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
-		models: data.NewModel(db),
+		config:  cfg,
+		logger:  logger,
+		mailer:  mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender, cfg.smtp.maxConns, tracker),
+		tracker: tracker,
+		models:  data.NewModel(db),
 	}
+	defer app.mailer.Close()
 
 	err = app.serve()
 	if err != nil {
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+func TestAuthenticate(t *testing.T) {
+	app := &application{}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		protected     bool
+		want          int
+	}{
+		{"public without a key", "", false, http.StatusNoContent},
+		{"public with a malformed header", "Basic dXNlcjpwYXNz", false, http.StatusNoContent},
+		{"public with a malformed key", "Bearer not-a-key", false, http.StatusNoContent},
+		{"protected without a key", "", true, http.StatusUnauthorized},
+		{"protected with a malformed header", "Basic dXNlcjpwYXNz", true, http.StatusUnauthorized},
+		{"protected with a malformed key", "Bearer not-a-key", true, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ok
+			if tt.protected {
+				handler = app.requireScope(data.ScopeRead, ok)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			app.authenticate(http.HandlerFunc(handler)).ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
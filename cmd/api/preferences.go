@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+var preferencesPage = template.Must(template.New("preferences").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<title>Email preferences</title>
+	<style>
+		body { font-family: Arial, sans-serif; background-color: #f0f0f0; color: #333; }
+		.container { max-width: 480px; margin: 80px auto; padding: 30px; background: #fff; border-radius: 4px; }
+		label { display: block; margin: 15px 0; }
+		label small { display: block; margin-left: 24px; color: #777; }
+		button { background: #1890ff; color: #fff; border: 0; border-radius: 4px; padding: 10px 25px; font-size: 16px; cursor: pointer; }
+		.notice { padding: 10px; background: #e6f7ff; border-radius: 4px; }
+	</style>
+</head>
+<body>
+	<div class="container">
+	{{if .Invalid}}
+		<h2>This link is not valid</h2>
+		<p>Please use the link from the most recent email you received.</p>
+	{{else}}
+		<h2>Email preferences</h2>
+		<p>Choose the emails {{.Address}} receives from us.</p>
+		{{if .Saved}}<p class="notice">Your preferences have been saved.</p>{{end}}
+		{{if .Suppressed}}<p class="notice">This address is unsubscribed from all emails, so none of the topics below are sent to it.</p>{{end}}
+		<form method="post">
+		{{range .Preferences}}
+			<label>
+				<input type="checkbox" name="topic" value="{{.Name}}"{{if .Subscribed}} checked{{end}}> {{.Name}}
+				{{with .Description}}<small>{{.}}</small>{{end}}
+			</label>
+		{{end}}
+			<button type="submit">Save preferences</button>
+		</form>
+		<p><a href="{{.UnsubscribeURL}}">Unsubscribe from all emails</a></p>
+	{{end}}
+	</div>
+</body>
+</html>`))
+
+type preferencesPageData struct {
+	Address        string
+	Preferences    []*data.TopicPreference
+	UnsubscribeURL string
+	Saved          bool
+	Suppressed     bool
+	Invalid        bool
+}
+
+// showPreferencesHandler renders the preference center of the recipient a
+// signed preferences link was sent to.
+func (app *application) showPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	recipient, err := app.signedLinkRecipient(r, app.tracker.VerifyPreferences)
+	if err != nil {
+		app.renderPreferencesPage(w, r, http.StatusNotFound, preferencesPageData{Invalid: true}, err)
+		return
+	}
+
+	app.showPreferences(w, r, recipient, false)
+}
+
+// updatePreferencesHandler subscribes the recipient to the checked topics
+// and opts them out of the others.
+func (app *application) updatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	recipient, err := app.signedLinkRecipient(r, app.tracker.VerifyPreferences)
+	if err != nil {
+		app.renderPreferencesPage(w, r, http.StatusNotFound, preferencesPageData{Invalid: true}, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+
+	err = r.ParseForm()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	topics, err := app.models.Topics.GetAll()
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	subscribed := []int64{}
+	for _, t := range topics {
+		for _, name := range r.PostForm["topic"] {
+			if name == t.Name {
+				subscribed = append(subscribed, t.ID)
+				break
+			}
+		}
+	}
+
+	err = app.models.Topics.SetPreferences(recipient.Recipient, subscribed)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	app.showPreferences(w, r, recipient, true)
+}
+
+func (app *application) showPreferences(w http.ResponseWriter, r *http.Request, recipient *data.Recipient, saved bool) {
+	preferences, err := app.models.Topics.GetPreferences(recipient.Recipient)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	suppressed, err := app.models.Suppressions.Check([]string{recipient.Recipient})
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	page := preferencesPageData{
+		Address:        recipient.Recipient,
+		Preferences:    preferences,
+		UnsubscribeURL: app.tracker.UnsubscribeURL(token),
+		Saved:          saved,
+		Suppressed:     len(suppressed) > 0,
+	}
+
+	app.renderPreferencesPage(w, r, http.StatusOK, page, nil)
+}
+
+func (app *application) renderPreferencesPage(w http.ResponseWriter, r *http.Request, status int, page preferencesPageData, err error) {
+	if err != nil && !errors.Is(err, errInvalidLink) {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	err = preferencesPage.Execute(w, page)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"expvar"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
+
+	"github.com/mayura-andrew/email-client/internal/data"
 )
 
 func (app *application) routes() http.Handler {
@@ -13,14 +15,71 @@ func (app *application) routes() http.Handler {
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
 	router.HandlerFunc(http.MethodGet, "/api/v1/healthcheck", app.healthcheckHandler)
-	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
+	router.HandlerFunc(http.MethodGet, "/debug/vars", app.requireScope(data.ScopeAdmin, expvar.Handler().ServeHTTP))
 
 	router.HandlerFunc(http.MethodGet, "/", app.rootHandler)
+	router.HandlerFunc(http.MethodGet, "/preferences/:token", app.showPreferencesHandler)
+	router.HandlerFunc(http.MethodPost, "/preferences/:token", app.updatePreferencesHandler)
 
-	router.HandlerFunc(http.MethodPost, "/api/v1/send", app.sendEmailHandler)
-	router.HandlerFunc(http.MethodGet, "/api/v1/sent", app.showEmailHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/send", app.requireScope(data.ScopeSend, app.idempotent(app.sendEmailHandler)))
+	router.HandlerFunc(http.MethodGet, "/api/v1/jobs/:id", app.requireScope(data.ScopeRead, app.showJobHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/emails/:id", app.requireScope(data.ScopeRead, app.getEmailHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/emails/:id/recipients/:rid", app.requireScope(data.ScopeRead, app.getRecipientHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/emails/:id/stats", app.requireScope(data.ScopeRead, app.showEmailStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/stats", app.requireScope(data.ScopeRead, app.showStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/sent", app.requireScope(data.ScopeRead, app.showEmailHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/exports/sent", app.requireScope(data.ScopeRead, app.exportSentHandler))
 	router.HandlerFunc(http.MethodGet, "/api/v1/redirect", app.track)
-	router.HandlerFunc(http.MethodGet, "/api/v1/recipients/:email", app.showEmailHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/t/open/:token", app.trackOpenHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/t/click/:token", app.trackClickHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/unsubscribe/:token", app.showUnsubscribeHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/unsubscribe/:token", app.unsubscribeHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/recipients/:email", app.requireScope(data.ScopeRead, app.showRecipientHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/bounces", app.requireScope(data.ScopeSend, app.createBounceHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/scheduled", app.requireScope(data.ScopeRead, app.listScheduledHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/scheduled/:id", app.requireScope(data.ScopeSend, app.rescheduleHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/scheduled/:id", app.requireScope(data.ScopeSend, app.cancelScheduledHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/templates", app.requireScope(data.ScopeAdmin, app.createTemplateHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/templates", app.requireScope(data.ScopeRead, app.listTemplatesHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/templates/:id", app.requireScope(data.ScopeRead, app.showTemplateHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/templates/:id/versions", app.requireScope(data.ScopeRead, app.listTemplateVersionsHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/templates/:id", app.requireScope(data.ScopeAdmin, app.updateTemplateHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/templates/:id", app.requireScope(data.ScopeAdmin, app.deleteTemplateHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/contacts", app.requireScope(data.ScopeRead, app.listContactsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/contacts", app.requireScope(data.ScopeAdmin, app.createContactHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/contacts/:id", app.requireScope(data.ScopeRead, app.showContactHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/contacts/:id", app.requireScope(data.ScopeAdmin, app.updateContactHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/contacts/:id", app.requireScope(data.ScopeAdmin, app.deleteContactHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/lists", app.requireScope(data.ScopeRead, app.listListsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/lists", app.requireScope(data.ScopeAdmin, app.createListHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/lists/:id", app.requireScope(data.ScopeRead, app.showListHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/lists/:id", app.requireScope(data.ScopeAdmin, app.updateListHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/lists/:id", app.requireScope(data.ScopeAdmin, app.deleteListHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/lists/:id/members", app.requireScope(data.ScopeRead, app.listMembersHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/lists/:id/members", app.requireScope(data.ScopeAdmin, app.addMembersHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/lists/:id/members/:contact_id", app.requireScope(data.ScopeAdmin, app.removeMemberHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/imports", app.requireScope(data.ScopeAdmin, app.createImportHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/imports/:id", app.requireScope(data.ScopeAdmin, app.showImportHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/imports/:id/rows", app.requireScope(data.ScopeAdmin, app.listImportRowsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/topics", app.requireScope(data.ScopeRead, app.listTopicsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/topics", app.requireScope(data.ScopeAdmin, app.createTopicHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/topics/:name", app.requireScope(data.ScopeAdmin, app.deleteTopicHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/suppressions", app.requireScope(data.ScopeAdmin, app.listSuppressionsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/suppressions", app.requireScope(data.ScopeAdmin, app.createSuppressionHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/suppressions/import", app.requireScope(data.ScopeAdmin, app.importSuppressionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/suppressions/:address", app.requireScope(data.ScopeAdmin, app.deleteSuppressionHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/keys", app.requireScope(data.ScopeAdmin, app.createAPIKeyHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/keys", app.requireScope(data.ScopeAdmin, app.listAPIKeysHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/keys/:id", app.requireScope(data.ScopeAdmin, app.revokeAPIKeyHandler))
 
-	return app.enableCORS(app.recoverPanic(app.rateLimit(router)))
+	return app.enableCORS(app.recoverPanic(app.rateLimit(app.authenticate(router))))
 }
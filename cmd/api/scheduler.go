@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// startScheduler launches the goroutine that queues scheduled emails once
+// their send_at has passed. Like the workers it stops when ctx is cancelled
+// and is waited on through app.wg.
+func (app *application) startScheduler(ctx context.Context) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+			}
+		}()
+
+		ticker := time.NewTicker(app.config.scheduler.interval)
+		defer ticker.Stop()
+
+		for {
+			app.dispatchDue()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (app *application) dispatchDue() {
+	n, err := app.models.Jobs.DispatchDue(time.Now())
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+
+	if n > 0 {
+		app.logger.PrintInfo("dispatched scheduled emails", map[string]string{
+			"count": fmt.Sprint(n),
+		})
+	}
+}
@@ -22,6 +22,17 @@ func (app *application) serve() error {
 
 	shutdownError := make(chan error)
 
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	app.background = ctx
+
+	app.failInterruptedImports()
+
+	app.startWorkers(ctx)
+	app.startScheduler(ctx)
+	app.startBouncePoller(ctx)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -34,7 +45,18 @@ func (app *application) serve() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
 
-		shutdownError <- srv.Shutdown(ctx)
+		// The background tasks are drained even when Shutdown fails, and
+		// its error is reported once they have stopped: serve only ever
+		// receives one value.
+		err := srv.Shutdown(ctx)
+
+		app.logger.PrintInfo("completing background tasks", map[string]string{
+			"addr": srv.Addr,
+		})
+
+		stopWorkers()
+		app.wg.Wait()
+		shutdownError <- err
 	}()
 
 	app.logger.PrintInfo("starting server", map[string]string{
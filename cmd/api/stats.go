@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+func (app *application) showStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.StatsFilters
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters.Sender = app.readString(qs, "sender", "")
+	filters.Since = app.readTime(qs, "since", v)
+	filters.Until = app.readUntil(qs, "until", v)
+	filters.Interval = app.readString(qs, "interval", "day")
+
+	app.writeStats(w, r, v, filters)
+}
+
+func (app *application) showEmailStatsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Emails.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+
+	filters := data.StatsFilters{
+		EmailID:  id,
+		Interval: app.readString(r.URL.Query(), "interval", "hour"),
+	}
+
+	app.writeStats(w, r, v, filters)
+}
+
+func (app *application) writeStats(w http.ResponseWriter, r *http.Request, v *validator.Validator, filters data.StatsFilters) {
+	if data.ValidateStatsFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stats, err := app.models.Stats.Get(filters)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"stats": stats}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
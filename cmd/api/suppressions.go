@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mayura-andrew/email-client/internal/data"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+// recipientResult is the outcome of a single address in a send request.
+type recipientResult struct {
+	Email  string `json:"email"`
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// applySuppressions drops every suppressed address, and every address that
+// opted out of the email's topic, from the email before it is enqueued and
+// reports what happened to each address.
+func (app *application) applySuppressions(email *data.Email) ([]recipientResult, error) {
+	var addresses []string
+	for _, list := range [][]data.Address{email.Recipients, email.CC, email.BCC} {
+		for _, a := range list {
+			addresses = append(addresses, a.Email)
+		}
+	}
+
+	suppressed, err := app.models.Suppressions.Check(addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	optedOut := map[string]bool{}
+	if email.TopicID != 0 {
+		optedOut, err = app.models.Topics.OptedOut(email.TopicID, addresses)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := []recipientResult{}
+
+	filter := func(kind string, list []data.Address) []data.Address {
+		kept := list[:0]
+
+		for _, a := range list {
+			if s, ok := suppressed[strings.ToLower(a.Email)]; ok {
+				results = append(results, recipientResult{Email: a.Email, Kind: kind, Status: "suppressed", Reason: s.Reason})
+				continue
+			}
+			if optedOut[strings.ToLower(a.Email)] {
+				results = append(results, recipientResult{Email: a.Email, Kind: kind, Status: "opted_out", Reason: email.Topic})
+				continue
+			}
+			results = append(results, recipientResult{Email: a.Email, Kind: kind, Status: "queued"})
+			kept = append(kept, a)
+		}
+		return kept
+	}
+
+	email.Recipients = filter(data.RecipientTo, email.Recipients)
+	email.CC = filter(data.RecipientCC, email.CC)
+	email.BCC = filter(data.RecipientBCC, email.BCC)
+
+	return results, nil
+}
+
+func (app *application) listSuppressionsHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.SuppressionFilters
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters.Address = app.readString(qs, "address", "")
+	filters.Reason = app.readString(qs, "reason", "")
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-created_at")
+	filters.SortSafelist = []string{"created_at", "address", "reason", "-created_at", "-address", "-reason"}
+
+	if data.ValidateFilters(v, filters.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	suppressions, metadata, err := app.models.Suppressions.GetAll(filters)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"suppressions": suppressions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) createSuppressionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Address string `json:"address"`
+		Reason  string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	s := &data.Suppression{
+		Address: input.Address,
+		Reason:  input.Reason,
+		Source:  data.SourceAPI,
+	}
+
+	if s.Reason == "" {
+		s.Reason = data.SuppressionManual
+	}
+
+	v := validator.New()
+
+	if data.ValidateSuppression(v, s); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Suppressions.Insert(s)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSuppression):
+			v.AddError("address", "is already suppressed")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"suppression": s}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// importSuppressionsHandler adds many addresses at once, either as JSON
+// ({"addresses": [...], "reason": "..."}) or as a text/csv body with the
+// address in the first column. Addresses that are already suppressed are
+// skipped.
+func (app *application) importSuppressionsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Addresses []string `json:"addresses"`
+		Reason    string   `json:"reason"`
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var err error
+
+	if mediaType == "text/csv" {
+		input.Reason = r.URL.Query().Get("reason")
+		input.Addresses, err = readAddressCSV(http.MaxBytesReader(w, r.Body, 10<<20))
+	} else {
+		err = app.readJSONLimit(w, r, &input, 10<<20)
+	}
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Reason == "" {
+		input.Reason = data.SuppressionManual
+	}
+
+	v := validator.New()
+
+	v.Check(len(input.Addresses) > 0, "addresses", "must be provided")
+	v.Check(len(input.Addresses) <= 100_000, "addresses", "must not contain more than 100000 addresses")
+	v.Check(validator.PermittedValue(input.Reason, data.SuppressionReasons...), "reason", "must be one of "+strings.Join(data.SuppressionReasons, ", "))
+
+	for i, address := range input.Addresses {
+		if !validator.Matches(address, validator.EmailRx) {
+			v.AddError("addresses", fmt.Sprintf("entry %d (%q) is not a valid email address", i+1, address))
+			break
+		}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	imported, err := app.models.Suppressions.Import(input.Addresses, input.Reason, data.SourceImport)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"imported": imported, "skipped": int64(len(input.Addresses)) - imported}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// readAddressCSV reads the first column of every row, skipping an
+// "address" or "email" header row.
+func readAddressCSV(r io.Reader) ([]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var addresses []string
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		address := strings.TrimSpace(record[0])
+
+		if len(addresses) == 0 && validator.In(strings.ToLower(address), "address", "email") {
+			continue
+		}
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses, nil
+}
+
+func (app *application) deleteSuppressionHandler(w http.ResponseWriter, r *http.Request) {
+	address := httprouter.ParamsFromContext(r.Context()).ByName("address")
+
+	err := app.models.Suppressions.Delete(address)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "suppression successfully removed"}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
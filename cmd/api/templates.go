@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+	"github.com/mayura-andrew/email-client/internal/mailer"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+// validateTemplate runs the field checks and makes sure every block parses,
+// so a broken template is rejected here rather than failing at send time.
+func (app *application) validateTemplate(v *validator.Validator, t *data.Template) {
+	if data.ValidateTemplate(v, t); !v.Valid() {
+		return
+	}
+
+	_, err := mailer.ParseTemplate(t.Subject, t.PlainBody, t.HTMLBody)
+	if err != nil {
+		v.AddError("template", err.Error())
+	}
+}
+
+func (app *application) createTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name      string `json:"name"`
+		Subject   string `json:"subject"`
+		PlainBody string `json:"plain_body"`
+		HTMLBody  string `json:"html_body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	t := &data.Template{
+		Name:      input.Name,
+		Subject:   input.Subject,
+		PlainBody: input.PlainBody,
+		HTMLBody:  input.HTMLBody,
+	}
+
+	v := validator.New()
+
+	if app.validateTemplate(v, t); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Templates.Insert(t)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTemplateName):
+			v.AddError("name", "a template with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/templates/%d", t.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"template": t}, headers)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) listTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	templates, err := app.models.Templates.GetAll()
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"templates": templates}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) showTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	t, err := app.models.Templates.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"template": t}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) listTemplateVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	versions, err := app.models.Templates.GetVersions(id)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	if len(versions) == 0 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"versions": versions}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+// updateTemplateHandler applies a partial update and stores the result as a
+// new version. Emails already queued keep the version they were sent with.
+func (app *application) updateTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	t, err := app.models.Templates.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name      *string `json:"name"`
+		Subject   *string `json:"subject"`
+		PlainBody *string `json:"plain_body"`
+		HTMLBody  *string `json:"html_body"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		t.Name = *input.Name
+	}
+	if input.Subject != nil {
+		t.Subject = *input.Subject
+	}
+	if input.PlainBody != nil {
+		t.PlainBody = *input.PlainBody
+	}
+	if input.HTMLBody != nil {
+		t.HTMLBody = *input.HTMLBody
+	}
+
+	v := validator.New()
+
+	if app.validateTemplate(v, t); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Templates.Update(t)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateTemplateName):
+			v.AddError("name", "a template with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"template": t}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
+
+func (app *application) deleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readRouteIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Templates.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrTemplateInUse):
+			app.errorResponse(w, r, http.StatusConflict, "the template has been used to send emails and cannot be deleted")
+		default:
+			app.serverErrorRespone(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "template successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+	}
+}
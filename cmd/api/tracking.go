@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+// pixel is a 1x1 transparent GIF.
+var pixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// fallbackURL is where clicks with a missing or tampered destination end up.
+const fallbackURL = "https://scholarx.sefglobal.org"
+
+// trackOpenHandler serves the tracking pixel and records the open. The pixel
+// is returned for every token, valid or not.
+func (app *application) trackOpenHandler(w http.ResponseWriter, r *http.Request) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	app.recordOpen(r, token)
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
+	w.Write(pixel)
+}
+
+// trackClickHandler records a click on a rewritten link and redirects to its
+// destination. The destination is only trusted when its signature matches;
+// anything else is sent to the fallback URL without being recorded.
+func (app *application) trackClickHandler(w http.ResponseWriter, r *http.Request) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+	qs := r.URL.Query()
+
+	target := qs.Get("url")
+
+	if !data.ValidTrackingToken(token) || !app.tracker.VerifyClick(token, target, qs.Get("sig")) {
+		http.Redirect(w, r, fallbackURL, http.StatusFound)
+		return
+	}
+
+	err := app.models.Clicks.Insert(token, target, r.UserAgent())
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// recordOpen stores an open event for token, flagging it as a machine open
+// when it comes from a known image proxy or scanner. Invalid tokens are
+// ignored.
+func (app *application) recordOpen(r *http.Request, token string) {
+	if !data.ValidTrackingToken(token) {
+		return
+	}
+
+	err := app.models.Events.InsertOpen(token, app.openEvent(r))
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// openEvent describes the open made by request r.
+func (app *application) openEvent(r *http.Request) *data.Event {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = ""
+	}
+	if app.config.tracking.anonymizeIP {
+		ip = anonymizeIP(ip)
+	}
+
+	return &data.Event{
+		UserAgent: r.UserAgent(),
+		IP:        ip,
+		Machine:   machineOpen(r.UserAgent()),
+	}
+}
+
+// machineUserAgents are fragments of the user agents sent by mail providers'
+// image proxies, security scanners and HTTP libraries that fetch every image
+// of a message whether or not anybody reads it.
+var machineUserAgents = []string{
+	"googleimageproxy",
+	"yahoomailproxy",
+	"bingpreview",
+	"barracuda",
+	"proofpoint",
+	"mimecast",
+	"crawler",
+	"spider",
+	"headlesschrome",
+	"python-requests",
+	"go-http-client",
+	"curl/",
+	"wget/",
+}
+
+// botRx matches crawler names such as "Googlebot/2.1" or "SomeBot;", and
+// "bot" as a word of its own, but not devices whose name merely contains
+// the letters, like Cubot phones.
+var botRx = regexp.MustCompile(`bot[/;]|\bbot\b`)
+
+// machineOpen reports whether an open was most likely made by a machine.
+// Apple Mail Privacy Protection fetches images with a bare "Mozilla/5.0"
+// user agent, and requests without any user agent are never browsers.
+func machineOpen(userAgent string) bool {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+
+	if ua == "" || ua == "mozilla/5.0" {
+		return true
+	}
+
+	for _, fragment := range machineUserAgents {
+		if strings.Contains(ua, fragment) {
+			return true
+		}
+	}
+	return botRx.MatchString(ua)
+}
+
+// anonymizeIP zeroes the host part of an address: the last octet of an IPv4
+// address and the last 80 bits of an IPv6 address.
+func anonymizeIP(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+
+	if v4 := addr.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return addr.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestMachineOpen(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      bool
+	}{
+		{"Gmail image proxy", "Mozilla/5.0 (Windows NT 5.1; rv:11.0) Gecko Firefox/11.0 (via ggpht.com GoogleImageProxy)", true},
+		{"Yahoo image proxy", "YahooMailProxy; https://help.yahoo.com/kb/yahoo-mail-proxy-SLN28749.html", true},
+		{"Apple Mail Privacy Protection", "Mozilla/5.0", true},
+		{"no user agent", "", true},
+		{"Googlebot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"Bing preview", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) BingPreview/1.0b", true},
+		{"Barracuda scanner", "Barracuda Sentinel (EE)", true},
+		{"Proofpoint scanner", "Mozilla/5.0 (compatible; Proofpoint URL Defense)", true},
+		{"Mimecast scanner", "Mimecast-URL-Protect/1.0", true},
+		{"generic bot", "LinkCheckBot/3.1 (+https://example.com/bot)", true},
+		{"headless browser", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36", true},
+		{"HTTP library", "python-requests/2.31.0", true},
+		{"Outlook for Windows", "Microsoft Office/16.0 (Windows NT 10.0; Microsoft Outlook 16.0.17126; Pro)", false},
+		{"Outlook on the web", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0", false},
+		{"Outlook for iOS", "Outlook-iOS/2.0", false},
+		{"Apple Mail on iPhone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148", false},
+		{"Thunderbird", "Mozilla/5.0 (X11; Linux x86_64; rv:115.0) Gecko/20100101 Thunderbird/115.5.0", false},
+		{"Gmail app on a Cubot phone", "Mozilla/5.0 (Linux; Android 11; CUBOT P50 Build/RP1A.200720.011; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/119.0.6045.163 Mobile Safari/537.36", false},
+		{"Cubot model code", "Mozilla/5.0 (Linux; Android 10; KINGKONG_MINI2_CUBOT) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := machineOpen(tt.userAgent); got != tt.want {
+				t.Errorf("machineOpen(%q) = %v, want %v", tt.userAgent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.25", "203.0.113.0"},
+		{"10.0.0.1", "10.0.0.0"},
+		{"2001:db8:85a3:8d3:1319:8a2e:370:7348", "2001:db8:85a3::"},
+		{"2001:db8::1", "2001:db8::"},
+		{"::1", "::"},
+		{"::ffff:203.0.113.25", "203.0.113.0"},
+		{"", ""},
+		{"not an address", ""},
+	}
+
+	for _, tt := range tests {
+		if got := anonymizeIP(tt.ip); got != tt.want {
+			t.Errorf("anonymizeIP(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
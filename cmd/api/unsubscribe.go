@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<title>Unsubscribe</title>
+	<style>
+		body { font-family: Arial, sans-serif; background-color: #f0f0f0; color: #333; }
+		.container { max-width: 480px; margin: 80px auto; padding: 30px; background: #fff; border-radius: 4px; text-align: center; }
+		button { background: #1890ff; color: #fff; border: 0; border-radius: 4px; padding: 10px 25px; font-size: 16px; cursor: pointer; }
+	</style>
+</head>
+<body>
+	<div class="container">
+	{{if .Invalid}}
+		<h2>This unsubscribe link is not valid</h2>
+		<p>Please use the link from the most recent email you received.</p>
+	{{else if .Done}}
+		<h2>You have been unsubscribed</h2>
+		<p>{{.Address}} will not receive any more emails from us.</p>
+	{{else}}
+		<h2>Unsubscribe</h2>
+		<p>Stop sending emails to {{.Address}}?</p>
+		<form method="post">
+			<button type="submit">Unsubscribe</button>
+		</form>
+	{{end}}
+	</div>
+</body>
+</html>`))
+
+type unsubscribePageData struct {
+	Address string
+	Done    bool
+	Invalid bool
+}
+
+// showUnsubscribeHandler renders the confirmation page. Nothing is changed on
+// GET, since mail scanners follow links on their own; the page posts back to
+// unsubscribeHandler.
+func (app *application) showUnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	recipient, err := app.unsubscribeRecipient(r)
+	if err != nil {
+		app.renderUnsubscribePage(w, r, http.StatusNotFound, unsubscribePageData{Invalid: true}, err)
+		return
+	}
+
+	app.renderUnsubscribePage(w, r, http.StatusOK, unsubscribePageData{Address: recipient.Recipient}, nil)
+}
+
+// unsubscribeHandler adds the recipient's address to the suppression list.
+// It serves both the confirmation form and RFC 8058 one-click requests,
+// which mail clients POST with a List-Unsubscribe=One-Click body.
+func (app *application) unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	recipient, err := app.unsubscribeRecipient(r)
+	if err != nil {
+		app.renderUnsubscribePage(w, r, http.StatusNotFound, unsubscribePageData{Invalid: true}, err)
+		return
+	}
+
+	err = app.models.Suppressions.Suppress(recipient.Recipient, data.SuppressionUnsubscribed, data.SourceUnsubscribe)
+	if err != nil {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	app.renderUnsubscribePage(w, r, http.StatusOK, unsubscribePageData{Address: recipient.Recipient, Done: true}, nil)
+}
+
+var errInvalidLink = errors.New("invalid link")
+
+func (app *application) unsubscribeRecipient(r *http.Request) (*data.Recipient, error) {
+	return app.signedLinkRecipient(r, app.tracker.VerifyUnsubscribe)
+}
+
+// signedLinkRecipient checks the signature of a link sent to a recipient,
+// using verify, and looks up the recipient it was sent to.
+func (app *application) signedLinkRecipient(r *http.Request, verify func(token, sig string) bool) (*data.Recipient, error) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	if !data.ValidTrackingToken(token) || !verify(token, r.URL.Query().Get("sig")) {
+		return nil, errInvalidLink
+	}
+
+	recipient, err := app.models.Emails.GetRecipientByToken(token)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, errInvalidLink
+		}
+		return nil, err
+	}
+
+	return recipient, nil
+}
+
+func (app *application) renderUnsubscribePage(w http.ResponseWriter, r *http.Request, status int, page unsubscribePageData, err error) {
+	if err != nil && !errors.Is(err, errInvalidLink) {
+		app.serverErrorRespone(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	err = unsubscribePage.Execute(w, page)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+	"github.com/mayura-andrew/email-client/internal/mailer"
+)
+
+// startWorkers launches the pool of goroutines that drain the delivery queue.
+// They stop claiming new work once ctx is cancelled and are waited on through
+// app.wg during shutdown.
+func (app *application) startWorkers(ctx context.Context) {
+	for i := 0; i < app.config.queue.workers; i++ {
+		app.wg.Add(1)
+
+		go func() {
+			defer app.wg.Done()
+
+			defer func() {
+				if err := recover(); err != nil {
+					app.logger.PrintError(fmt.Errorf("%s", err), nil)
+				}
+			}()
+
+			app.runWorker(ctx)
+		}()
+	}
+}
+
+func (app *application) runWorker(ctx context.Context) {
+	for ctx.Err() == nil {
+		delivery, err := app.models.Jobs.Claim(app.config.queue.lease)
+		if err != nil {
+			if !errors.Is(err, data.ErrRecordNotFound) {
+				app.logger.PrintError(err, nil)
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(app.config.queue.pollInterval):
+			}
+			continue
+		}
+
+		app.deliver(delivery)
+	}
+}
+
+func (app *application) deliver(d *data.Delivery) {
+	properties := map[string]string{
+		"job_id":    fmt.Sprint(d.JobID),
+		"recipient": d.Recipient,
+	}
+
+	// The last allowed attempt was claimed but never reported back, most
+	// likely because the process stopped mid-send.
+	if d.Attempts > app.config.retry.maxAttempts {
+		err := fmt.Errorf("gave up after %d attempts that did not finish", d.Attempts-1)
+		app.logger.PrintError(err, properties)
+		app.recordFailure(d, err, false, properties)
+		return
+	}
+
+	// The address may have been suppressed, by a bounce, a complaint or an
+	// unsubscribe, or opted out of the topic after the email was queued.
+	suppressed, err := app.models.Suppressions.Check([]string{d.Recipient})
+	if err != nil {
+		err = fmt.Errorf("checking suppressions: %w", err)
+		app.logger.PrintError(err, properties)
+		app.recordFailure(d, err, true, properties)
+		return
+	}
+
+	if s, ok := suppressed[strings.ToLower(d.Recipient)]; ok {
+		app.skip(d, data.SkippedSuppressed, "suppressed: "+s.Reason, properties)
+		return
+	}
+
+	if d.TopicID != 0 {
+		optedOut, err := app.models.Topics.OptedOut(d.TopicID, []string{d.Recipient})
+		if err != nil {
+			err = fmt.Errorf("checking topic preferences: %w", err)
+			app.logger.PrintError(err, properties)
+			app.recordFailure(d, err, true, properties)
+			return
+		}
+
+		if optedOut[strings.ToLower(d.Recipient)] {
+			app.skip(d, data.SkippedOptedOut, "opted out of "+d.Topic, properties)
+			return
+		}
+	}
+
+	// Failing to load the template or the attachments is most likely a
+	// database hiccup, so it is retried with backoff like a transient SMTP
+	// error, until the attempts run out.
+	tmpl, err := app.emailTemplate(d)
+	if err != nil {
+		err = fmt.Errorf("loading template: %w", err)
+		app.logger.PrintError(err, properties)
+		app.recordFailure(d, err, true, properties)
+		return
+	}
+
+	var attachments []*data.Attachment
+
+	if d.HasAttachments {
+		attachments, err = app.mailer.Attachments(d.EmailID, app.loadAttachments)
+		if err != nil {
+			err = fmt.Errorf("loading attachments: %w", err)
+			app.logger.PrintError(err, properties)
+			app.recordFailure(d, err, true, properties)
+			return
+		}
+	}
+
+	err = app.mailer.Send(&mailer.Message{
+		To:        d.Recipient,
+		VisibleTo: d.To,
+		CC:        d.CC,
+		ReplyTo:   d.ReplyTo,
+		Template:  tmpl,
+		Data: mailer.EmailData{
+			Subject:   d.Subject,
+			Body:      d.Body,
+			Recipient: d.Recipient,
+			EmailId:   d.RecipientID,
+			Token:     d.Token,
+			URL:       app.config.url,
+			Data:      mergeData(d.TemplateData, d.RecipientData),
+		},
+		Attachments: attachments,
+	})
+	if err != nil {
+		app.logger.PrintError(err, properties)
+		app.recordFailure(d, err, mailer.IsTemporary(err), properties)
+		return
+	}
+
+	err = app.markSent(d, properties)
+	if err != nil {
+		app.logger.PrintError(err, properties)
+		return
+	}
+
+	app.completeJob(d, properties)
+}
+
+// statusWriteDelay is the longest wait between attempts to record a sent
+// email.
+const statusWriteDelay = 5 * time.Second
+
+// markSent records the recipient as sent. The email is already out, so a
+// failed write is retried until it succeeds or until just before the lease
+// runs out: once it has, the recipient is claimed and sent again anyway.
+func (app *application) markSent(d *data.Delivery, properties map[string]string) error {
+	delay := 100 * time.Millisecond
+
+	for {
+		err := app.models.Emails.UpdateEmailStatus(d.RecipientID)
+		if err == nil {
+			return nil
+		}
+
+		// Leave room for the next write's own 3 second timeout.
+		if time.Now().Add(delay + 3*time.Second).After(d.LockedUntil) {
+			return fmt.Errorf("recording sent email: %w", err)
+		}
+
+		app.logger.PrintError(err, properties)
+		time.Sleep(delay)
+		delay = min(2*delay, statusWriteDelay)
+	}
+}
+
+// emailTemplate returns the parsed template the email was queued with, or the
+// built-in template when it was sent without one.
+func (app *application) emailTemplate(d *data.Delivery) (*mailer.Template, error) {
+	if d.TemplateID == 0 {
+		return app.mailer.DefaultTemplate()
+	}
+	return app.mailer.Template(d.TemplateID, d.TemplateVersion, app.models.Templates.GetVersion)
+}
+
+// loadAttachments fetches the attachments of an email with their contents.
+func (app *application) loadAttachments(emailID int64) ([]*data.Attachment, error) {
+	return app.models.Attachments.GetForEmail(emailID, true)
+}
+
+// recordFailure schedules another attempt for temporary errors, or marks the
+// recipient failed when the error is permanent or retries are exhausted.
+func (app *application) recordFailure(d *data.Delivery, sendErr error, temporary bool, properties map[string]string) {
+	if temporary && d.Attempts < app.config.retry.maxAttempts {
+		retryAt := time.Now().Add(app.config.retry.backoff.Delay(d.Attempts))
+
+		err := app.models.Jobs.Retry(d.RecipientID, sendErr.Error(), retryAt)
+		if err != nil {
+			app.logger.PrintError(err, properties)
+		}
+		return
+	}
+
+	err := app.models.Jobs.Fail(d.RecipientID, sendErr.Error())
+	if err != nil {
+		app.logger.PrintError(err, properties)
+		return
+	}
+
+	app.completeJob(d, properties)
+}
+
+// skip marks the recipient skipped instead of sending it.
+func (app *application) skip(d *data.Delivery, reason, detail string, properties map[string]string) {
+	err := app.models.Jobs.Skip(d.RecipientID, reason, detail)
+	if err != nil {
+		app.logger.PrintError(err, properties)
+		return
+	}
+
+	app.completeJob(d, properties)
+}
+
+func (app *application) completeJob(d *data.Delivery, properties map[string]string) {
+	err := app.models.Jobs.Complete(d.JobID)
+	if err != nil {
+		app.logger.PrintError(err, properties)
+	}
+}
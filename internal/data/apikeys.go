@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+const (
+	ScopeSend  = "send"
+	ScopeRead  = "read"
+	ScopeAdmin = "admin"
+)
+
+var Scopes = []string{ScopeSend, ScopeRead, ScopeAdmin}
+
+type APIKey struct {
+	ID        int64          `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	Name      string         `json:"name"`
+	Plaintext string         `json:"key,omitempty"`
+	Hash      []byte         `json:"-"`
+	Scopes    []string       `json:"scopes"`
+	RevokedAt CustomNullTime `json:"revoked_at"`
+}
+
+// HasScope reports whether the key grants scope. The admin scope grants
+// everything.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// generateAPIKey creates a key from 16 random bytes. Only the SHA-256 hash is
+// ever stored; the plaintext is returned to the caller once, on creation.
+func generateAPIKey(name string, scopes []string) (*APIKey, error) {
+	key := &APIKey{
+		Name:   name,
+		Scopes: scopes,
+	}
+
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	hash := sha256.Sum256([]byte(key.Plaintext))
+	key.Hash = hash[:]
+
+	return key, nil
+}
+
+func ValidateAPIKeyPlaintext(v *validator.Validator, plaintext string) {
+	v.Check(plaintext != "", "key", "must be provided")
+	v.Check(len(plaintext) == 26, "key", "must be 26 bytes long")
+}
+
+func ValidateAPIKey(v *validator.Validator, key *APIKey) {
+	v.Check(key.Name != "", "name", "must be provided")
+	v.Check(len(key.Name) <= 255, "name", "must not be more than 255 bytes long")
+	v.Check(len(key.Scopes) != 0, "scopes", "must contain at least 1 scope")
+	v.Check(validator.Unique(key.Scopes), "scopes", "must not contain duplicate values")
+
+	for _, scope := range key.Scopes {
+		v.Check(validator.PermittedValue(scope, Scopes...), "scopes", "must only contain send, read or admin")
+	}
+}
+
+type APIKeyModel struct {
+	DB *sql.DB
+}
+
+func (m APIKeyModel) New(name string, scopes []string) (*APIKey, error) {
+	key, err := generateAPIKey(name, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(key)
+	return key, err
+}
+
+func (m APIKeyModel) Insert(key *APIKey) error {
+	query := `INSERT INTO api_keys (name, hash, scopes) VALUES ($1, $2, $3) RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, key.Name, key.Hash, pq.Array(key.Scopes)).Scan(&key.ID, &key.CreatedAt)
+}
+
+func (m APIKeyModel) GetAll() ([]*APIKey, error) {
+	query := `SELECT id, created_at, name, scopes, revoked_at FROM api_keys ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+
+	for rows.Next() {
+		var key APIKey
+		err = rows.Scan(&key.ID, &key.CreatedAt, &key.Name, pq.Array(&key.Scopes), &key.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// GetByPlaintext looks up an unrevoked key by its plaintext value.
+func (m APIKeyModel) GetByPlaintext(plaintext string) (*APIKey, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `SELECT id, created_at, name, scopes, revoked_at FROM api_keys WHERE hash = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var key APIKey
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(&key.ID, &key.CreatedAt, &key.Name, pq.Array(&key.Scopes), &key.RevokedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &key, nil
+}
+
+func (m APIKeyModel) Revoke(id int64) error {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"time"
+
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+const (
+	MaxAttachments         = 10
+	MaxAttachmentSize      = 10 << 20
+	MaxTotalAttachmentSize = 20 << 20
+)
+
+var (
+	PermittedAttachmentTypes = []string{
+		"application/pdf",
+		"image/png",
+		"image/jpeg",
+		"image/gif",
+		"text/plain",
+		"text/csv",
+		"text/calendar",
+		"application/zip",
+		"application/msword",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.ms-excel",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/vnd.ms-powerpoint",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	}
+
+	ContentIDRx = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+(@[a-zA-Z0-9.-]+)?$`)
+)
+
+// Attachment is a file sent with an email. ContentID is set for inline
+// attachments, which the HTML body references as "cid:<ContentID>".
+type Attachment struct {
+	ID          int64  `json:"id"`
+	EmailID     int64  `json:"email_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	ContentID   string `json:"content_id,omitempty"`
+	Size        int    `json:"size"`
+	Content     []byte `json:"-"`
+}
+
+func ValidateAttachments(v *validator.Validator, attachments []*Attachment) {
+	v.Check(len(attachments) <= MaxAttachments, "attachments", "must not contain more than 10 files")
+
+	total := 0
+	var contentIDs []string
+
+	for _, a := range attachments {
+		total += len(a.Content)
+
+		v.Check(a.Filename != "", "attachments", "must all have a filename")
+		v.Check(len(a.Filename) <= 255, "attachments", "filenames must not be more than 255 bytes long")
+		v.Check(len(a.Content) != 0, "attachments", "must not be empty")
+		v.Check(len(a.Content) <= MaxAttachmentSize, "attachments", "must each be at most 10MB")
+		v.Check(validator.PermittedValue(a.ContentType, PermittedAttachmentTypes...), "attachments", "content type "+a.ContentType+" is not permitted")
+
+		if a.ContentID != "" {
+			v.Check(validator.Matches(a.ContentID, ContentIDRx), "attachments", "content_id must not contain spaces or angle brackets")
+			contentIDs = append(contentIDs, a.ContentID)
+		}
+	}
+
+	v.Check(total <= MaxTotalAttachmentSize, "attachments", "must be at most 20MB in total")
+	v.Check(validator.Unique(contentIDs), "attachments", "must not contain duplicate content IDs")
+}
+
+func insertAttachments(ctx context.Context, tx *sql.Tx, email *Email) error {
+	query := `INSERT INTO attachments (email_id, filename, content_type, content_id, size, content)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id`
+
+	for _, a := range email.Attachments {
+		a.EmailID = email.ID
+		a.Size = len(a.Content)
+
+		args := []any{a.EmailID, a.Filename, a.ContentType, a.ContentID, a.Size, a.Content}
+
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&a.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type AttachmentModel struct {
+	DB *sql.DB
+}
+
+// GetForEmail returns the attachments of an email. The file contents are
+// only loaded when withContent is set.
+func (m AttachmentModel) GetForEmail(emailID int64, withContent bool) ([]*Attachment, error) {
+	query := `SELECT id, email_id, filename, content_type, content_id, size, CASE WHEN $2 THEN content END
+	FROM attachments
+	WHERE email_id = $1
+	ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, emailID, withContent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*Attachment{}
+
+	for rows.Next() {
+		var a Attachment
+		err = rows.Scan(&a.ID, &a.EmailID, &a.Filename, &a.ContentType, &a.ContentID, &a.Size, &a.Content)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	BounceHard = "hard"
+	BounceSoft = "soft"
+)
+
+// Bounce is a delivery failure reported after the message was accepted by the
+// SMTP server, as parsed from a delivery status notification.
+type Bounce struct {
+	RecipientID    int64     `json:"recipient_id"`
+	EmailID        int64     `json:"email_id"`
+	Recipient      string    `json:"recipient"`
+	Type           string    `json:"type"`
+	Status         string    `json:"status"`
+	DiagnosticCode string    `json:"diagnostic_code"`
+	BouncedAt      time.Time `json:"bounced_at"`
+}
+
+// RecordBounce stores a bounce on the recipient with the given tracking
+// token. A hard bounce is never downgraded by a later soft one; in that case,
+// as for unknown tokens, ErrRecordNotFound is returned.
+func (e EmailModel) RecordBounce(token string, b *Bounce) error {
+	query := `UPDATE recipients SET bounce_type = $2, bounce_status = $3, bounce_diagnostic = $4, bounced_at = $5
+	WHERE token = $1 AND NOT (bounce_type = 'hard' AND $2 = 'soft')
+	RETURNING id, email_id, recipient`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if b.BouncedAt.IsZero() {
+		b.BouncedAt = time.Now()
+	}
+
+	args := []any{token, b.Type, b.Status, b.DiagnosticCode, b.BouncedAt}
+
+	err := e.DB.QueryRowContext(ctx, query, args...).Scan(&b.RecipientID, &b.EmailID, &b.Recipient)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Click is a tracked link followed by a recipient.
+type Click struct {
+	ID          int64     `json:"id"`
+	RecipientID int64     `json:"recipient_id"`
+	URL         string    `json:"url"`
+	UserAgent   string    `json:"user_agent"`
+	ClickedAt   time.Time `json:"clicked_at"`
+}
+
+type ClickModel struct {
+	DB *sql.DB
+}
+
+// Insert records a click for the recipient with the given tracking token.
+// Unknown tokens are silently ignored.
+func (m ClickModel) Insert(token, url, userAgent string) error {
+	query := `INSERT INTO clicks (recipient_id, url, user_agent)
+	SELECT id, $2, $3 FROM recipients WHERE token = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, token, url, userAgent)
+	return err
+}
+
+// GetForRecipient returns the clicks of a recipient, oldest first.
+func (m ClickModel) GetForRecipient(recipientID int64) ([]*Click, error) {
+	query := `SELECT id, recipient_id, url, user_agent, clicked_at FROM clicks
+	WHERE recipient_id = $1 ORDER BY clicked_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, recipientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clicks := []*Click{}
+
+	for rows.Next() {
+		var c Click
+		err = rows.Scan(&c.ID, &c.RecipientID, &c.URL, &c.UserAgent, &c.ClickedAt)
+		if err != nil {
+			return nil, err
+		}
+		clicks = append(clicks, &c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return clicks, nil
+}
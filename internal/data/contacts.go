@@ -0,0 +1,272 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+var ErrDuplicateContact = errors.New("duplicate contact")
+
+// Contact is a known recipient with the merge fields used when it is sent
+// to through a list. Addresses are stored in lower case.
+type Contact struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Address    string    `json:"address"`
+	Name       string    `json:"name"`
+	Attributes JSONMap   `json:"attributes"`
+	Version    int       `json:"version"`
+}
+
+// Data returns the merge fields of the contact: its attributes together
+// with its name, if it has one.
+func (c *Contact) Data() map[string]any {
+	data := make(map[string]any, len(c.Attributes)+1)
+	for k, v := range c.Attributes {
+		data[k] = v
+	}
+	if c.Name != "" {
+		data["name"] = c.Name
+	}
+	return data
+}
+
+func ValidateContact(v *validator.Validator, c *Contact) {
+	v.Check(c.Address != "", "address", "must be provided")
+	v.Check(len(c.Address) <= 255, "address", "must not be more than 255 bytes long")
+	v.Check(validator.Matches(c.Address, validator.EmailRx), "address", "must be a valid email address")
+	v.Check(len(c.Name) <= 255, "name", "must not be more than 255 bytes long")
+}
+
+type ContactModel struct {
+	DB *sql.DB
+}
+
+func (m ContactModel) Insert(c *Contact) error {
+	query := `INSERT INTO contacts (address, name, attributes) VALUES (LOWER($1), $2, $3)
+	RETURNING id, address, created_at, updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, c.Address, c.Name, c.Attributes).Scan(&c.ID, &c.Address, &c.CreatedAt, &c.UpdatedAt, &c.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "contacts_address_key"`:
+			return ErrDuplicateContact
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Upsert creates every contact whose address is not known yet and adds all
+// of them to list listID, unless it is 0. Known contacts get the new name,
+// if one is given, and the new attributes merged into theirs. Only the first
+// of several entries with the same address is used. It returns how many
+// contacts were added to the list.
+func (m ContactModel) Upsert(contacts []*Contact, listID int64) (int64, error) {
+	var addresses, names, attributes []string
+
+	seen := make(map[string]bool, len(contacts))
+
+	for _, c := range contacts {
+		address := strings.ToLower(c.Address)
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+
+		js, err := json.Marshal(c.Attributes)
+		if err != nil {
+			return 0, err
+		}
+		if c.Attributes == nil {
+			js = []byte("{}")
+		}
+
+		addresses = append(addresses, address)
+		names = append(names, c.Name)
+		attributes = append(attributes, string(js))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO contacts (address, name, attributes)
+	SELECT address, name, attributes::jsonb FROM unnest($1::text[], $2::text[], $3::text[]) AS t(address, name, attributes)
+	ON CONFLICT (address) DO UPDATE SET
+		name = CASE WHEN EXCLUDED.name <> '' THEN EXCLUDED.name ELSE contacts.name END,
+		attributes = contacts.attributes || EXCLUDED.attributes,
+		updated_at = NOW(),
+		version = contacts.version + 1
+	WHERE (EXCLUDED.name <> '' AND EXCLUDED.name <> contacts.name) OR NOT contacts.attributes @> EXCLUDED.attributes`
+
+	_, err = tx.ExecContext(ctx, query, pq.Array(addresses), pq.Array(names), pq.Array(attributes))
+	if err != nil {
+		return 0, err
+	}
+
+	var added int64
+
+	if listID != 0 {
+		query = `INSERT INTO list_members (list_id, contact_id)
+		SELECT $1, id FROM contacts WHERE address = ANY($2)
+		ON CONFLICT DO NOTHING`
+
+		result, err := tx.ExecContext(ctx, query, listID, pq.Array(addresses))
+		if err != nil {
+			switch {
+			case err.Error() == `pq: insert or update on table "list_members" violates foreign key constraint "list_members_list_id_fkey"`:
+				return 0, ErrRecordNotFound
+			default:
+				return 0, err
+			}
+		}
+
+		added, err = result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return added, nil
+}
+
+func (m ContactModel) Get(id int64) (*Contact, error) {
+	query := `SELECT id, created_at, updated_at, address, name, attributes, version FROM contacts WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var c Contact
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt, &c.Address, &c.Name, &c.Attributes, &c.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+// ContactFilters narrows down the contacts. Zero values mean "no filter".
+type ContactFilters struct {
+	Address string
+	Name    string
+	ListID  int64
+	Filters
+}
+
+func (m ContactModel) GetAll(filters ContactFilters) ([]*Contact, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id, created_at, updated_at, address, name, attributes, version
+	FROM contacts
+	WHERE (address LIKE '%%' || LOWER($1) || '%%' OR $1 = '')
+	AND (name ILIKE '%%' || $2 || '%%' OR $2 = '')
+	AND ($3 = 0 OR EXISTS (SELECT 1 FROM list_members WHERE list_members.list_id = $3 AND list_members.contact_id = contacts.id))
+	ORDER BY %s %s, id ASC
+	LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.Address, filters.Name, filters.ListID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	contacts := []*Contact{}
+
+	for rows.Next() {
+		var c Contact
+		err = rows.Scan(&totalRecords, &c.ID, &c.CreatedAt, &c.UpdatedAt, &c.Address, &c.Name, &c.Attributes, &c.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		contacts = append(contacts, &c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return contacts, metadata, nil
+}
+
+// Update stores c if it is still at the version that was read.
+func (m ContactModel) Update(c *Contact) error {
+	query := `UPDATE contacts SET address = LOWER($1), name = $2, attributes = $3, updated_at = NOW(), version = version + 1
+	WHERE id = $4 AND version = $5
+	RETURNING address, updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, c.Address, c.Name, c.Attributes, c.ID, c.Version).Scan(&c.Address, &c.UpdatedAt, &c.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "contacts_address_key"`:
+			return ErrDuplicateContact
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the contact and its list memberships. Emails already
+// sent to it are kept.
+func (m ContactModel) Delete(id int64) error {
+	query := `DELETE FROM contacts WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
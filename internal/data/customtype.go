@@ -2,7 +2,9 @@ package data
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"time"
 )
 
@@ -35,3 +37,22 @@ func (nt *CustomNullTime) UnmarshalJSON(b []byte) error {
 	nt.Valid = aux.Valid
 	return nil
 }
+
+// JSONMap is a JSON object stored in a JSONB column. A nil map is stored as
+// an empty object.
+type JSONMap map[string]any
+
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m)
+}
+
+func (m *JSONMap) Scan(src any) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, m)
+}
@@ -1,12 +1,17 @@
 package data
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base32"
+	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/mayura-andrew/email-client/internal/validator"
 )
 
@@ -14,9 +19,28 @@ type Email struct {
 	ID         int64     `json:"id"`
 	CreatedAt  time.Time `json:"-"`
 	Sender     string    `json:"sender"`
-	Recipients []string  `json:"recipients,omitempty"`
+	Recipients []Address `json:"recipients,omitempty"`
+	CC         []Address `json:"cc,omitempty"`
+	BCC        []Address `json:"bcc,omitempty"`
+	ReplyTo    []string  `json:"reply_to,omitempty"`
 	Body       string    `json:"body"`
 	Subject    string    `json:"Subject"`
+	APIKeyID   int64     `json:"api_key_id,omitempty"`
+
+	// SendAt delays delivery until the given time; State is scheduled until
+	// then, and queued once the email has been handed to the workers.
+	SendAt *time.Time `json:"send_at,omitempty"`
+	State  string     `json:"state"`
+
+	TemplateID      int64   `json:"template_id,omitempty"`
+	TemplateVersion int     `json:"template_version,omitempty"`
+	TemplateData    JSONMap `json:"template_data,omitempty"`
+
+	// TopicID is the topic recipients can opt out of, if any.
+	TopicID int64  `json:"-"`
+	Topic   string `json:"topic,omitempty"`
+
+	Attachments []*Attachment `json:"attachments,omitempty"`
 }
 
 type EmailRecipient struct {
@@ -24,6 +48,7 @@ type EmailRecipient struct {
 	CreatedAt  time.Time      `json:"createdAt"`
 	Sender     string         `json:"sender"`
 	Recipient  string         `json:"recipients"`
+	Kind       string         `json:"kind"`
 	Body       string         `json:"body"`
 	Subject    string         `json:"subject"`
 	Status     string         `json:"status"`
@@ -32,6 +57,88 @@ type EmailRecipient struct {
 	OpenedTime CustomNullTime `json:"openedTime"`
 }
 
+// Address is a recipient in a send request together with its merge fields,
+// which the template sees under .Data. In JSON it is either a plain address
+// string or an object: {"email": "alice@example.com", "data": {"name": "Alice"}}.
+type Address struct {
+	Email string         `json:"email"`
+	Data  map[string]any `json:"data,omitempty"`
+}
+
+func (a *Address) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &a.Email)
+	}
+
+	var aux struct {
+		Email string         `json:"email"`
+		Data  map[string]any `json:"data"`
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&aux); err != nil {
+		return err
+	}
+
+	a.Email = aux.Email
+	a.Data = aux.Data
+	return nil
+}
+
+func emails(addresses []Address) []string {
+	list := make([]string, len(addresses))
+	for i, a := range addresses {
+		list[i] = a.Email
+	}
+	return list
+}
+
+const (
+	RecipientTo  = "to"
+	RecipientCC  = "cc"
+	RecipientBCC = "bcc"
+)
+
+// Recipient is a single delivery of an email, one row of the recipients table.
+// Kind records whether the address was given as to, cc or bcc.
+type Recipient struct {
+	ID         int64          `json:"id"`
+	EmailID    int64          `json:"email_id"`
+	Recipient  string         `json:"recipient"`
+	Kind       string         `json:"kind"`
+	Data       JSONMap        `json:"data,omitempty"`
+	Status     bool           `json:"status"`
+	SentTime   time.Time      `json:"sent_time"`
+	Opened     bool           `json:"opened"`
+	OpenedTime CustomNullTime `json:"opened_time"`
+
+	FirstOpenedAt    CustomNullTime `json:"first_opened_at"`
+	OpenCount        int            `json:"open_count"`
+	MachineOpenCount int            `json:"machine_open_count"`
+
+	BounceType       string         `json:"bounce_type,omitempty"`
+	BounceStatus     string         `json:"bounce_status,omitempty"`
+	BounceDiagnostic string         `json:"bounce_diagnostic,omitempty"`
+	BouncedAt        CustomNullTime `json:"bounced_at"`
+
+	Attempts  int     `json:"attempts"`
+	LastError *string `json:"last_error"`
+	Failed    bool    `json:"failed"`
+	Skipped   string  `json:"skipped,omitempty"`
+}
+
+const recipientColumns = `recipients.id, recipients.email_id, recipients.recipient, recipients.kind, recipients.data, recipients.status, recipients.sent_time,
+	recipients.opened, recipients.opened_time, recipients.first_opened_at, recipients.open_count, recipients.machine_open_count,
+	recipients.bounce_type, recipients.bounce_status, recipients.bounce_diagnostic, recipients.bounced_at,
+	recipients.attempts, recipients.last_error, recipients.failed, recipients.skipped`
+
+func (r *Recipient) scanDest() []any {
+	return []any{&r.ID, &r.EmailID, &r.Recipient, &r.Kind, &r.Data, &r.Status, &r.SentTime, &r.Opened, &r.OpenedTime, &r.FirstOpenedAt, &r.OpenCount, &r.MachineOpenCount,
+		&r.BounceType, &r.BounceStatus, &r.BounceDiagnostic, &r.BouncedAt, &r.Attempts, &r.LastError, &r.Failed, &r.Skipped}
+}
+
 type EmailModel struct {
 	DB *sql.DB
 }
@@ -42,56 +149,123 @@ func ValidateEmail(v *validator.Validator, email *Email) {
 	v.Check(len(email.Recipients) != 0, "recipients", "must be provided")
 	v.Check(len(email.Recipients) >= 1, "recipients", "must contain more than 1 recipient emails")
 	// v.Check(validator.Unique(email.Recipients), "recipients", "must not contain duplicate recipient emails")
+
+	validateAddresses(v, "recipients", emails(email.Recipients))
+	validateAddresses(v, "cc", emails(email.CC))
+	validateAddresses(v, "bcc", emails(email.BCC))
+	validateAddresses(v, "reply_to", email.ReplyTo)
+
+	ValidateAttachments(v, email.Attachments)
+
+	if email.SendAt != nil {
+		ValidateSendAt(v, *email.SendAt)
+	}
+
+	// A stored template supplies its own subject and body.
+	if email.TemplateID != 0 {
+		return
+	}
+
 	v.Check(email.Subject != "", "subject", "must be provided")
 	v.Check(len(email.Subject) >= 1, "sender", "must be more than 1 bytes long")
 	v.Check(email.Body != "", "body", "must be provided")
 	v.Check(len(email.Body) >= 1, "body", "must be more than 1 bytes long")
 }
 
-func (e EmailModel) InsertEmail(email *Email, recipient string) (int64, error) {
-	query := `INSERT INTO emails (sender, body, subject) VALUES ($1, $2, $3) RETURNING id, created_at`
+func validateAddresses(v *validator.Validator, key string, addresses []string) {
+	for _, address := range addresses {
+		v.Check(validator.Matches(address, validator.EmailRx), key, "must only contain valid email addresses")
+	}
+}
 
-	args := []any{email.Sender, email.Body, email.Subject}
+func (e EmailModel) Get(id int64) (*Email, error) {
+	query := `SELECT emails.id, emails.created_at, sender, body, subject, reply_to, COALESCE(api_key_id, 0),
+	send_at, state, COALESCE(template_id, 0), COALESCE(template_version, 0), template_data, COALESCE(topic_id, 0), COALESCE(topics.name, '')
+	FROM emails LEFT JOIN topics ON topics.id = emails.topic_id
+	WHERE emails.id = $1`
 
-	err := e.DB.QueryRow(query, args...).Scan(&email.ID, &email.CreatedAt)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
+	var email Email
+
+	err := e.DB.QueryRowContext(ctx, query, id).Scan(&email.ID, &email.CreatedAt, &email.Sender, &email.Body, &email.Subject, pq.Array(&email.ReplyTo), &email.APIKeyID,
+		&email.SendAt, &email.State, &email.TemplateID, &email.TemplateVersion, &email.TemplateData, &email.TopicID, &email.Topic)
 	if err != nil {
-		return 0, err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
-	emailID, err := e.InsertEmailRecipient(email, recipient)
+
+	return &email, nil
+}
+
+func (e EmailModel) GetRecipients(emailID int64) ([]*Recipient, error) {
+	query := `SELECT ` + recipientColumns + ` FROM recipients WHERE email_id = $1 ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := e.DB.QueryContext(ctx, query, emailID)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
+	defer rows.Close()
+
+	recipients := []*Recipient{}
 
-	return emailID, nil
+	for rows.Next() {
+		var r Recipient
+		err = rows.Scan(r.scanDest()...)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return recipients, nil
 }
 
-func (e EmailModel) InsertEmailRecipient(email *Email, recipient string) (int64, error) {
+// GetRecipient returns a single delivery, scoped to its email so that a
+// recipient ID cannot be looked up under the wrong email.
+func (e EmailModel) GetRecipient(emailID, id int64) (*Recipient, error) {
+	query := `SELECT ` + recipientColumns + ` FROM recipients WHERE email_id = $1 AND id = $2`
 
-	query := `INSERT INTO recipients (email_id, recipient, status, sent_time, opened)
-	VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-	args := []any{email.ID, recipient, false, time.Now(), false}
+	var r Recipient
 
-	ctx, cancle := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancle()
-	var id int64
-	err := e.DB.QueryRowContext(ctx, query, args...).Scan(&id)
+	err := e.DB.QueryRowContext(ctx, query, emailID, id).Scan(r.scanDest()...)
 	if err != nil {
-		return 0, err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
-	return id, nil
-}
 
-func (e EmailModel) GetAllSent()(*[]EmailRecipient, error) {
+	return &r, nil
+}
 
-	query := `SELECT recipients.id, recipients.recipient, recipients.status, recipients.sent_time, recipients.opened, recipients.opened_time, emails.created_at, emails.sender, emails.body, emails.subject FROM recipients JOIN emails ON recipients.email_id = emails.id;`
+// GetRecipientByToken returns the delivery with the given tracking token.
+func (e EmailModel) GetRecipientByToken(token string) (*Recipient, error) {
+	query := `SELECT ` + recipientColumns + ` FROM recipients WHERE token = $1`
 
-	ctx, cancle := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancle()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-	rows, err := e.DB.QueryContext(ctx, query)
+	var r Recipient
 
+	err := e.DB.QueryRowContext(ctx, query, token).Scan(r.scanDest()...)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -100,41 +274,207 @@ func (e EmailModel) GetAllSent()(*[]EmailRecipient, error) {
 			return nil, err
 		}
 	}
+
+	return &r, nil
+}
+
+// SentFilters narrows down the sent history. Zero values mean "no filter".
+type SentFilters struct {
+	Sender string
+	Status string
+	Opened *bool
+	Since  time.Time
+	Until  time.Time
+	Filters
+}
+
+func ValidateSentFilters(v *validator.Validator, f SentFilters) {
+	ValidateFilters(v, f.Filters)
+	validateSentFields(v, f)
+}
+
+// ValidateSentExportFilters checks the filters of an export, which has no
+// pages but keeps the sort order.
+func ValidateSentExportFilters(v *validator.Validator, f SentFilters) {
+	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+	validateSentFields(v, f)
+}
+
+func validateSentFields(v *validator.Validator, f SentFilters) {
+	v.Check(f.Status == "" || validator.In(f.Status, "sent", "pending", "failed", "skipped"), "status", "must be one of sent, pending, failed or skipped")
+	v.Check(f.Since.IsZero() || f.Until.IsZero() || f.Since.Before(f.Until), "until", "must be later than since")
+}
+
+// where returns the WHERE clause shared by every query over the sent history,
+// with its arguments starting at $1.
+func (f SentFilters) where() (string, []any) {
+	clause := `WHERE (LOWER(emails.sender) = LOWER($1) OR $1 = '')
+	AND ($2 = '' OR ($2 = 'sent' AND recipients.status) OR ($2 = 'failed' AND recipients.failed)
+		OR ($2 = 'skipped' AND recipients.skipped <> '')
+		OR ($2 = 'pending' AND NOT recipients.status AND NOT recipients.failed AND recipients.skipped = ''))
+	AND (recipients.opened = $3 OR $3::boolean IS NULL)
+	AND (recipients.sent_time >= $4 OR $4::timestamptz IS NULL)
+	AND (recipients.sent_time < $5 OR $5::timestamptz IS NULL)`
+
+	return clause, []any{f.Sender, f.Status, f.Opened, nullTime(f.Since), nullTime(f.Until)}
+}
+
+// sortColumn returns the qualified column to order by, since the sent
+// history joins recipients and emails.
+func (f SentFilters) sortColumn() string {
+	switch column := f.Filters.sortColumn(); column {
+	case "subject":
+		return "emails.subject"
+	default:
+		return "recipients." + column
+	}
+}
+
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func (e EmailModel) GetAllSent(filters SentFilters) ([]*EmailRecipient, Metadata, error) {
+	where, args := filters.where()
+
+	query := fmt.Sprintf(`SELECT count(*) OVER(), recipients.id, recipients.recipient, recipients.kind, recipients.status, recipients.sent_time, recipients.opened, recipients.opened_time, emails.created_at, emails.sender, emails.body, emails.subject
+	FROM recipients JOIN emails ON recipients.email_id = emails.id
+	%s
+	ORDER BY %s %s, recipients.id ASC
+	LIMIT $6 OFFSET $7`, where, filters.sortColumn(), filters.sortDirection())
+
+	args = append(args, filters.limit(), filters.offset())
+
+	ctx, cancle := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancle()
+
+	rows, err := e.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
 	defer rows.Close()
 
-	var details []EmailRecipient
-	
+	totalRecords := 0
+	details := []*EmailRecipient{}
+
 	for rows.Next() {
-		d := EmailRecipient{}
-	    err = rows.Scan(&d.ID, &d.Recipient, &d.Status, &d.SentTime, &d.Opened, &d.OpenedTime, &d.CreatedAt, &d.Sender, &d.Body, &d.Subject)
+		var d EmailRecipient
+		err = rows.Scan(&totalRecords, &d.ID, &d.Recipient, &d.Kind, &d.Status, &d.SentTime, &d.Opened, &d.OpenedTime, &d.CreatedAt, &d.Sender, &d.Body, &d.Subject)
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, err
 		}
-		details = append(details, d)
+		details = append(details, &d)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
-	return &details, nil
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return details, metadata, nil
 }
 
-func (e EmailModel) UpdateEmail(id int64) error {
-	query := `UPDATE recipients SET opened = true, opened_time = $1 WHERE id = $2`
+// Engagement summarises what a single address has received. Sent only
+// counts deliveries the SMTP server accepted, and Delivered those of them
+// that did not hard bounce later; deliveries still in the queue, given up on
+// or skipped are counted separately.
+type Engagement struct {
+	Sent      int     `json:"sent"`
+	Delivered int     `json:"delivered"`
+	Pending   int     `json:"pending"`
+	Failed    int     `json:"failed"`
+	Skipped   int     `json:"skipped"`
+	Opened    int     `json:"opened"`
+	OpenRate  float64 `json:"open_rate"`
+}
 
-	args := []any{time.Now(), id}
+// GetByRecipient returns the full send/open history of one address, matched
+// case-insensitively, newest first, together with its engagement totals.
+func (e EmailModel) GetByRecipient(recipient string) ([]*EmailRecipient, Engagement, error) {
+	query := `SELECT recipients.id, recipients.recipient, recipients.kind, recipients.status, recipients.sent_time, recipients.opened, recipients.opened_time, emails.created_at, emails.sender, emails.body, emails.subject
+	FROM recipients JOIN emails ON recipients.email_id = emails.id
+	WHERE LOWER(recipients.recipient) = LOWER($1)
+	ORDER BY recipients.sent_time DESC, recipients.id DESC`
 
-	_, err := e.DB.Exec(query, args...)
-	return err
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := e.DB.QueryContext(ctx, query, recipient)
+	if err != nil {
+		return nil, Engagement{}, err
+	}
+	defer rows.Close()
+
+	history := []*EmailRecipient{}
+
+	for rows.Next() {
+		var d EmailRecipient
+		err = rows.Scan(&d.ID, &d.Recipient, &d.Kind, &d.Status, &d.SentTime, &d.Opened, &d.OpenedTime, &d.CreatedAt, &d.Sender, &d.Body, &d.Subject)
+		if err != nil {
+			return nil, Engagement{}, err
+		}
+		history = append(history, &d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Engagement{}, err
+	}
+
+	query = `SELECT COUNT(*) FILTER (WHERE status), COUNT(*) FILTER (WHERE status AND bounce_type <> 'hard'),
+	COUNT(*) FILTER (WHERE NOT status AND NOT failed AND skipped = ''), COUNT(*) FILTER (WHERE failed), COUNT(*) FILTER (WHERE skipped <> ''),
+	COUNT(*) FILTER (WHERE opened),
+	COALESCE(COUNT(*) FILTER (WHERE opened)::float / NULLIF(COUNT(*) FILTER (WHERE status), 0), 0)
+	FROM recipients
+	WHERE LOWER(recipient) = LOWER($1)`
+
+	var totals Engagement
+
+	err = e.DB.QueryRowContext(ctx, query, recipient).Scan(&totals.Sent, &totals.Delivered, &totals.Pending, &totals.Failed, &totals.Skipped,
+		&totals.Opened, &totals.OpenRate)
+	if err != nil {
+		return nil, Engagement{}, err
+	}
+
+	return history, totals, nil
+}
+
+// generateTrackingToken creates the random token that identifies a recipient
+// in tracking links, so that opens cannot be recorded by guessing IDs.
+func generateTrackingToken() (string, error) {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+// ValidTrackingToken reports whether token has the shape of a tracking token,
+// so that malformed values never reach the database.
+func ValidTrackingToken(token string) bool {
+	if len(token) != 26 {
+		return false
+	}
+
+	_, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(token)
+	return err == nil
 }
 
 func (e EmailModel) UpdateEmailStatus(id int64) error {
 
-	query := `UPDATE recipients SET status = true , sent_time = $1 WHERE id = $2`
+	query := `UPDATE recipients SET status = true , sent_time = $1, next_attempt_at = NULL, locked_until = NULL WHERE id = $2`
 
 	args := []any{time.Now(), id}
 
-	_, err := e.DB.Exec(query, args...)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := e.DB.ExecContext(ctx, query, args...)
 	return err
 }
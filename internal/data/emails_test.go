@@ -0,0 +1,21 @@
+package data
+
+import "testing"
+
+func TestSentFiltersSortColumn(t *testing.T) {
+	safelist := []string{"sent_time", "opened_time", "recipient", "subject", "-sent_time", "-opened_time", "-recipient", "-subject"}
+
+	tests := map[string]string{
+		"sent_time":    "recipients.sent_time",
+		"-opened_time": "recipients.opened_time",
+		"recipient":    "recipients.recipient",
+		"-subject":     "emails.subject",
+	}
+
+	for sort, want := range tests {
+		f := SentFilters{Filters: Filters{Sort: sort, SortSafelist: safelist}}
+		if got := f.sortColumn(); got != want {
+			t.Errorf("sortColumn() for %q = %q, want %q", sort, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const EventOpen = "open"
+
+// Event is a single tracked interaction with a delivered email. Machine is
+// set for opens made by image proxies and link scanners rather than people;
+// they are kept for reference but not counted as opens.
+type Event struct {
+	ID          int64     `json:"id"`
+	RecipientID int64     `json:"recipient_id"`
+	Type        string    `json:"type"`
+	CreatedAt   time.Time `json:"created_at"`
+	UserAgent   string    `json:"user_agent"`
+	IP          string    `json:"ip"`
+	Machine     bool      `json:"machine"`
+}
+
+type EventModel struct {
+	DB *sql.DB
+}
+
+// InsertOpen records an open for the recipient with the given tracking token
+// and updates its open totals. A human open marks the recipient opened and
+// sets first_opened_at the first time; a machine open only counts towards
+// machine_open_count. Unknown tokens are silently ignored.
+func (m EventModel) InsertOpen(token string, event *Event) error {
+	return m.insertOpen("token = $1", token, event)
+}
+
+// InsertOpenByRecipientID is InsertOpen for emails sent before tracking
+// tokens, whose links identify the recipient by its id.
+func (m EventModel) InsertOpenByRecipientID(recipientID int64, event *Event) error {
+	return m.insertOpen("id = $1", recipientID, event)
+}
+
+func (m EventModel) insertOpen(match string, key any, event *Event) error {
+	query := `WITH e AS (
+		INSERT INTO email_events (recipient_id, type, created_at, user_agent, ip, machine)
+		SELECT id, $2, $3, $4, $5, $6 FROM recipients WHERE ` + match + `
+		RETURNING recipient_id
+	)
+	UPDATE recipients SET
+		opened = opened OR NOT $6,
+		opened_time = CASE WHEN $6 THEN opened_time ELSE $3 END,
+		first_opened_at = CASE WHEN $6 THEN first_opened_at ELSE COALESCE(first_opened_at, $3) END,
+		open_count = open_count + CASE WHEN $6 THEN 0 ELSE 1 END,
+		machine_open_count = machine_open_count + CASE WHEN $6 THEN 1 ELSE 0 END
+	FROM e WHERE recipients.id = e.recipient_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	event.Type = EventOpen
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	args := []any{key, event.Type, event.CreatedAt, event.UserAgent, event.IP, event.Machine}
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetForRecipient returns the events of a recipient, oldest first.
+func (m EventModel) GetForRecipient(recipientID int64) ([]*Event, error) {
+	query := `SELECT id, recipient_id, type, created_at, user_agent, ip, machine FROM email_events
+	WHERE recipient_id = $1 ORDER BY created_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, recipientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+
+	for rows.Next() {
+		var e Event
+		err = rows.Scan(&e.ID, &e.RecipientID, &e.Type, &e.CreatedAt, &e.UserAgent, &e.IP, &e.Machine)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
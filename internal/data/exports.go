@@ -0,0 +1,116 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SentExport is one delivery in an export of the sent history. Timestamps
+// are null when the event has not happened.
+type SentExport struct {
+	RecipientID      int64      `json:"recipient_id"`
+	EmailID          int64      `json:"email_id"`
+	Sender           string     `json:"sender"`
+	Recipient        string     `json:"recipient"`
+	Kind             string     `json:"kind"`
+	Subject          string     `json:"subject"`
+	Topic            string     `json:"topic"`
+	Status           string     `json:"status"`
+	Attempts         int        `json:"attempts"`
+	LastError        string     `json:"last_error"`
+	QueuedAt         time.Time  `json:"queued_at"`
+	SentAt           *time.Time `json:"sent_at"`
+	FirstOpenedAt    *time.Time `json:"first_opened_at"`
+	LastOpenedAt     *time.Time `json:"last_opened_at"`
+	OpenCount        int        `json:"open_count"`
+	MachineOpenCount int        `json:"machine_open_count"`
+	FirstClickedAt   *time.Time `json:"first_clicked_at"`
+	LastClickedAt    *time.Time `json:"last_clicked_at"`
+	ClickCount       int        `json:"click_count"`
+	BounceType       string     `json:"bounce_type"`
+	BouncedAt        *time.Time `json:"bounced_at"`
+}
+
+// sentExportBatchSize is how many rows SentCursor.Next fetches at a time.
+const sentExportBatchSize = 1000
+
+// SentCursor walks an export of the sent history through a server-side
+// cursor, so only one batch of rows is held in memory at a time. It must be
+// closed.
+type SentCursor struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+// ExportSent opens a cursor over every delivery matched by filters, in the
+// order of filters.Sort. Paging is ignored. The cursor lives as long as ctx.
+func (e EmailModel) ExportSent(ctx context.Context, filters SentFilters) (*SentCursor, error) {
+	where, args := filters.where()
+
+	query := fmt.Sprintf(`DECLARE sent_export NO SCROLL CURSOR FOR
+	SELECT recipients.id, recipients.email_id, emails.sender, recipients.recipient, recipients.kind, emails.subject, COALESCE(topics.name, ''),
+		CASE WHEN recipients.status THEN 'sent' WHEN recipients.failed THEN 'failed' WHEN recipients.skipped <> '' THEN 'skipped' ELSE 'pending' END,
+		recipients.attempts, COALESCE(recipients.last_error, ''), emails.created_at,
+		CASE WHEN recipients.status THEN recipients.sent_time END,
+		recipients.first_opened_at, CASE WHEN recipients.opened THEN recipients.opened_time END,
+		recipients.open_count, recipients.machine_open_count,
+		click_stats.first_clicked_at, click_stats.last_clicked_at, click_stats.click_count,
+		recipients.bounce_type, recipients.bounced_at
+	FROM recipients JOIN emails ON recipients.email_id = emails.id
+	LEFT JOIN topics ON topics.id = emails.topic_id
+	LEFT JOIN LATERAL (
+		SELECT MIN(clicks.clicked_at) AS first_clicked_at, MAX(clicks.clicked_at) AS last_clicked_at, COUNT(*) AS click_count
+		FROM clicks WHERE clicks.recipient_id = recipients.id
+	) AS click_stats ON true
+	%s
+	ORDER BY %s %s, recipients.id ASC`, where, filters.sortColumn(), filters.sortDirection())
+
+	tx, err := e.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return &SentCursor{ctx: ctx, tx: tx}, nil
+}
+
+// Next returns the next batch of rows, or an empty batch once the export is
+// done.
+func (c *SentCursor) Next() ([]*SentExport, error) {
+	rows, err := c.tx.QueryContext(c.ctx, fmt.Sprintf(`FETCH FORWARD %d FROM sent_export`, sentExportBatchSize))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batch := make([]*SentExport, 0, sentExportBatchSize)
+
+	for rows.Next() {
+		var s SentExport
+		err = rows.Scan(&s.RecipientID, &s.EmailID, &s.Sender, &s.Recipient, &s.Kind, &s.Subject, &s.Topic, &s.Status,
+			&s.Attempts, &s.LastError, &s.QueuedAt, &s.SentAt, &s.FirstOpenedAt, &s.LastOpenedAt, &s.OpenCount, &s.MachineOpenCount,
+			&s.FirstClickedAt, &s.LastClickedAt, &s.ClickCount, &s.BounceType, &s.BouncedAt)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// Close ends the transaction the cursor lives in.
+func (c *SentCursor) Close() error {
+	return c.tx.Rollback()
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// IdempotencyRecord is a request made with an Idempotency-Key header. Status
+// is zero while the original request is still being processed; afterwards
+// the record holds the response to replay.
+type IdempotencyRecord struct {
+	APIKeyID    int64
+	Key         string
+	CreatedAt   time.Time
+	RequestHash []byte
+	Status      int
+	Location    string
+	Response    []byte
+}
+
+type IdempotencyModel struct {
+	DB *sql.DB
+}
+
+// Reserve claims key for a new request. It returns nil when the caller holds
+// the key and should process the request, or the existing record when the key
+// was used within window. A record older than window is replaced, and so is a
+// reservation left unfinished for more than a minute, which is longer than
+// the server's write timeout.
+func (m IdempotencyModel) Reserve(apiKeyID int64, key string, requestHash []byte, window time.Duration) (*IdempotencyRecord, error) {
+	query := `INSERT INTO idempotency_keys (api_key_id, key, request_hash)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (api_key_id, key) DO UPDATE
+	SET created_at = NOW(), request_hash = EXCLUDED.request_hash, status = 0, location = '', response = NULL
+	WHERE idempotency_keys.created_at < NOW() - make_interval(secs => $4)
+	OR (idempotency_keys.status = 0 AND idempotency_keys.created_at < NOW() - INTERVAL '1 minute')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, apiKeyID, key, requestHash, window.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 1 {
+		return nil, nil
+	}
+
+	query = `SELECT api_key_id, key, created_at, request_hash, status, location, COALESCE(response, '')
+	FROM idempotency_keys WHERE api_key_id = $1 AND key = $2`
+
+	var record IdempotencyRecord
+
+	err = m.DB.QueryRowContext(ctx, query, apiKeyID, key).Scan(&record.APIKeyID, &record.Key, &record.CreatedAt, &record.RequestHash,
+		&record.Status, &record.Location, &record.Response)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// Released between the insert and the select; let the caller
+			// try again.
+			return nil, ErrEditConflict
+		default:
+			return nil, err
+		}
+	}
+
+	return &record, nil
+}
+
+// Complete stores the response of a reserved request for replay.
+func (m IdempotencyModel) Complete(record *IdempotencyRecord) error {
+	query := `UPDATE idempotency_keys SET status = $1, location = $2, response = $3
+	WHERE api_key_id = $4 AND key = $5`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, record.Status, record.Location, record.Response, record.APIKeyID, record.Key)
+	return err
+}
+
+// Release drops a reservation so that the request can be retried, for
+// instance after a server error.
+func (m IdempotencyModel) Release(apiKeyID int64, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE api_key_id = $1 AND key = $2 AND status = 0`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, apiKeyID, key)
+	return err
+}
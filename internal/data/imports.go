@@ -0,0 +1,199 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// What happened to a row of an import.
+const (
+	RowAccepted = "accepted"
+	RowSkipped  = "skipped"
+	RowInvalid  = "invalid"
+)
+
+var ImportRowStatuses = []string{RowAccepted, RowSkipped, RowInvalid}
+
+// ContactImport is a CSV upload that is processed in the background. Size and
+// BytesProcessed are in bytes of the uploaded file, so they tell how far
+// along the import is before the number of rows is known.
+type ContactImport struct {
+	ID             int64          `json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	ListID         int64          `json:"list_id,omitempty"`
+	Status         string         `json:"status"`
+	Size           int64          `json:"size"`
+	BytesProcessed int64          `json:"bytes_processed"`
+	Rows           int            `json:"rows"`
+	Accepted       int            `json:"accepted"`
+	Skipped        int            `json:"skipped"`
+	Invalid        int            `json:"invalid"`
+	Added          int64          `json:"added"`
+	Error          string         `json:"error,omitempty"`
+	CompletedAt    CustomNullTime `json:"completed_at"`
+}
+
+// ImportRow is the outcome of a single row. Row counts the header as row 1,
+// like a spreadsheet does.
+type ImportRow struct {
+	Row     int    `json:"row"`
+	Address string `json:"address"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type ContactImportModel struct {
+	DB *sql.DB
+}
+
+func (m ContactImportModel) Insert(imp *ContactImport) error {
+	query := `INSERT INTO contact_imports (list_id, status, size) VALUES (NULLIF($1, 0), $2, $3)
+	RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, imp.ListID, imp.Status, imp.Size).Scan(&imp.ID, &imp.CreatedAt)
+}
+
+func (m ContactImportModel) Get(id int64) (*ContactImport, error) {
+	query := `SELECT id, created_at, COALESCE(list_id, 0), status, size, bytes_processed, total_rows, accepted, skipped, invalid, added, error, completed_at
+	FROM contact_imports WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var imp ContactImport
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&imp.ID, &imp.CreatedAt, &imp.ListID, &imp.Status, &imp.Size, &imp.BytesProcessed,
+		&imp.Rows, &imp.Accepted, &imp.Skipped, &imp.Invalid, &imp.Added, &imp.Error, &imp.CompletedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &imp, nil
+}
+
+// Progress stores the report of a batch of rows together with the counters
+// and status of the import.
+func (m ContactImportModel) Progress(imp *ContactImport, rows []ImportRow) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(rows) > 0 {
+		numbers := make([]int64, len(rows))
+		addresses := make([]string, len(rows))
+		statuses := make([]string, len(rows))
+		reasons := make([]string, len(rows))
+
+		for i, r := range rows {
+			numbers[i] = int64(r.Row)
+			addresses[i] = r.Address
+			statuses[i] = r.Status
+			reasons[i] = r.Reason
+		}
+
+		query := `INSERT INTO contact_import_rows (import_id, row_number, address, status, reason)
+		SELECT $1, unnest($2::int[]), unnest($3::text[]), unnest($4::text[]), unnest($5::text[])`
+
+		_, err = tx.ExecContext(ctx, query, imp.ID, pq.Array(numbers), pq.Array(addresses), pq.Array(statuses), pq.Array(reasons))
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `UPDATE contact_imports SET status = $1, bytes_processed = $2, total_rows = $3, accepted = $4, skipped = $5, invalid = $6, added = $7, error = $8,
+		completed_at = CASE WHEN $1 IN ('completed', 'failed') THEN NOW() END
+	WHERE id = $9
+	RETURNING completed_at`
+
+	err = tx.QueryRowContext(ctx, query, imp.Status, imp.BytesProcessed, imp.Rows, imp.Accepted, imp.Skipped, imp.Invalid, imp.Added, imp.Error, imp.ID).Scan(&imp.CompletedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FailInterrupted marks the imports that were still being processed when the
+// server stopped as failed. Their uploads are not kept across restarts.
+func (m ContactImportModel) FailInterrupted() (int64, error) {
+	query := `UPDATE contact_imports SET status = 'failed', error = 'interrupted by a restart', completed_at = NOW()
+	WHERE status IN ('queued', 'running')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ImportRowFilters narrows down the report of an import.
+type ImportRowFilters struct {
+	Status string
+	Filters
+}
+
+func (m ContactImportModel) GetRows(importID int64, filters ImportRowFilters) ([]*ImportRow, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), row_number, address, status, reason
+	FROM contact_import_rows
+	WHERE import_id = $1 AND (status = $2 OR $2 = '')
+	ORDER BY %s %s
+	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, importID, filters.Status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	report := []*ImportRow{}
+
+	for rows.Next() {
+		var r ImportRow
+		err = rows.Scan(&totalRecords, &r.Row, &r.Address, &r.Status, &r.Reason)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		report = append(report, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return report, metadata, nil
+}
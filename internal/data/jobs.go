@@ -0,0 +1,315 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Reasons a recipient is skipped instead of sent.
+const (
+	SkippedSuppressed = "suppressed"
+	SkippedOptedOut   = "opted_out"
+)
+
+const (
+	JobScheduled = "scheduled"
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobCancelled = "cancelled"
+)
+
+type Job struct {
+	ID          int64          `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	EmailID     int64          `json:"email_id"`
+	Status      string         `json:"status"`
+	Total       int            `json:"total"`
+	Sent        int            `json:"sent"`
+	Failed      int            `json:"failed"`
+	Skipped     int            `json:"skipped"`
+	CompletedAt CustomNullTime `json:"completed_at"`
+}
+
+// Delivery is a single recipient of a queued email, claimed by a worker.
+type Delivery struct {
+	RecipientID int64
+	JobID       int64
+	EmailID     int64
+	Recipient   string
+	Token       string
+	Kind        string
+	Sender      string
+	Subject     string
+	Body        string
+
+	// To and CC are the visible to and cc addresses of the email. To is only
+	// filled in for cc and bcc copies: each to recipient sees just their
+	// own address.
+	To      []string
+	CC      []string
+	ReplyTo []string
+
+	TemplateID      int64
+	TemplateVersion int
+	TemplateData    JSONMap
+	RecipientData   JSONMap
+
+	HasAttachments bool
+
+	// TopicID is the subscription topic the email was sent under, or 0.
+	TopicID int64
+	Topic   string
+
+	// Attempts counts this attempt: Claim increments it, so an attempt
+	// that never reports back, because the process crashed mid-send, still
+	// counts towards the retry limit.
+	Attempts int
+
+	// LockedUntil is when the lease taken by Claim runs out.
+	LockedUntil time.Time
+}
+
+type JobModel struct {
+	DB *sql.DB
+}
+
+// Enqueue stores the email, one recipients row per address and the job that
+// drives their delivery in a single transaction. An email with a SendAt is
+// stored as scheduled and is left alone by the workers until DispatchDue
+// queues it.
+func (j JobModel) Enqueue(email *Email) (*Job, error) {
+	email.State = EmailQueued
+	if email.SendAt != nil {
+		email.State = EmailScheduled
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := j.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO emails (sender, body, subject, reply_to, api_key_id, template_id, template_version, template_data, send_at, state, topic_id)
+	VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, 0), NULLIF($7, 0), $8, $9, $10, NULLIF($11, 0))
+	RETURNING id, created_at`
+
+	// pq sends a nil slice as NULL, which the NOT NULL reply_to column
+	// rejects.
+	replyTo := email.ReplyTo
+	if replyTo == nil {
+		replyTo = []string{}
+	}
+
+	args := []any{email.Sender, email.Body, email.Subject, pq.Array(replyTo), email.APIKeyID, email.TemplateID, email.TemplateVersion, email.TemplateData,
+		email.SendAt, email.State, email.TopicID}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&email.ID, &email.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses, kinds, fields, tokens []string
+
+	for _, group := range []struct {
+		kind string
+		list []Address
+	}{{RecipientTo, email.Recipients}, {RecipientCC, email.CC}, {RecipientBCC, email.BCC}} {
+		for _, address := range group.list {
+			js, err := json.Marshal(JSONMap(address.Data))
+			if err != nil {
+				return nil, err
+			}
+
+			token, err := generateTrackingToken()
+			if err != nil {
+				return nil, err
+			}
+
+			addresses = append(addresses, address.Email)
+			kinds = append(kinds, group.kind)
+			fields = append(fields, string(js))
+			tokens = append(tokens, token)
+		}
+	}
+
+	query = `INSERT INTO recipients (email_id, recipient, kind, data, token)
+	SELECT $1, unnest($2::text[]), unnest($3::text[]), unnest($4::text[])::jsonb, unnest($5::text[])`
+
+	_, err = tx.ExecContext(ctx, query, email.ID, pq.Array(addresses), pq.Array(kinds), pq.Array(fields), pq.Array(tokens))
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertAttachments(ctx, tx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		EmailID: email.ID,
+		Status:  JobQueued,
+		Total:   len(addresses),
+	}
+	if email.State == EmailScheduled {
+		job.Status = JobScheduled
+	}
+
+	query = `INSERT INTO jobs (email_id, status) VALUES ($1, $2) RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, job.EmailID, job.Status).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (j JobModel) Get(id int64) (*Job, error) {
+	query := `SELECT jobs.id, jobs.created_at, jobs.email_id, jobs.status, jobs.completed_at,
+	COUNT(recipients.id), COUNT(recipients.id) FILTER (WHERE recipients.status = true),
+	COUNT(recipients.id) FILTER (WHERE recipients.failed = true), COUNT(recipients.id) FILTER (WHERE recipients.skipped <> '')
+	FROM jobs LEFT JOIN recipients ON recipients.email_id = jobs.email_id
+	WHERE jobs.id = $1
+	GROUP BY jobs.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var job Job
+
+	err := j.DB.QueryRowContext(ctx, query, id).Scan(&job.ID, &job.CreatedAt, &job.EmailID, &job.Status, &job.CompletedAt, &job.Total, &job.Sent, &job.Failed, &job.Skipped)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &job, nil
+}
+
+// Claim leases the next unsent recipient of a queued or running job that is due
+// for an attempt until now+lease. A lease left behind by a crashed or
+// restarted process simply expires, so the recipient is picked up again by
+// the next Claim. Every claim counts as an attempt.
+func (j JobModel) Claim(lease time.Duration) (*Delivery, error) {
+	query := `WITH next AS (
+		SELECT recipients.id FROM recipients
+		INNER JOIN jobs ON jobs.email_id = recipients.email_id
+		WHERE jobs.status IN ('queued', 'running') AND recipients.status = false AND recipients.failed = false AND recipients.skipped = ''
+		AND (recipients.locked_until IS NULL OR recipients.locked_until < $1)
+		AND (recipients.next_attempt_at IS NULL OR recipients.next_attempt_at <= $1)
+		ORDER BY recipients.id
+		LIMIT 1
+		FOR UPDATE OF recipients SKIP LOCKED
+	)
+	UPDATE recipients SET locked_until = $2, attempts = recipients.attempts + 1
+	FROM next, jobs, emails
+	WHERE recipients.id = next.id AND jobs.email_id = recipients.email_id AND emails.id = recipients.email_id
+	RETURNING recipients.id, jobs.id, emails.id, recipients.recipient, COALESCE(recipients.token, ''), recipients.kind, recipients.attempts, emails.sender, emails.subject, emails.body,
+		CASE WHEN recipients.kind = 'to' THEN '{}' ELSE ARRAY(SELECT r.recipient FROM recipients r WHERE r.email_id = emails.id AND r.kind = 'to' ORDER BY r.id) END,
+		ARRAY(SELECT r.recipient FROM recipients r WHERE r.email_id = emails.id AND r.kind = 'cc' ORDER BY r.id),
+		emails.reply_to, recipients.data,
+		COALESCE(emails.template_id, 0), COALESCE(emails.template_version, 0), emails.template_data,
+		EXISTS (SELECT 1 FROM attachments WHERE attachments.email_id = emails.id),
+		COALESCE(emails.topic_id, 0), COALESCE((SELECT topics.name FROM topics WHERE topics.id = emails.topic_id), '')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	var d Delivery
+
+	err := j.DB.QueryRowContext(ctx, query, now, now.Add(lease)).Scan(&d.RecipientID, &d.JobID, &d.EmailID, &d.Recipient, &d.Token, &d.Kind, &d.Attempts, &d.Sender, &d.Subject, &d.Body,
+		pq.Array(&d.To), pq.Array(&d.CC), pq.Array(&d.ReplyTo), &d.RecipientData,
+		&d.TemplateID, &d.TemplateVersion, &d.TemplateData, &d.HasAttachments,
+		&d.TopicID, &d.Topic)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	d.LockedUntil = now.Add(lease)
+
+	query = `UPDATE jobs SET status = $1 WHERE id = $2 AND status = $3`
+
+	_, err = j.DB.ExecContext(ctx, query, JobRunning, d.JobID, JobQueued)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// Retry records the error of a failed attempt and makes the recipient claimable again at
+// retryAt.
+func (j JobModel) Retry(recipientID int64, lastError string, retryAt time.Time) error {
+	query := `UPDATE recipients SET last_error = $1, next_attempt_at = $2, locked_until = NULL
+	WHERE id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := j.DB.ExecContext(ctx, query, lastError, retryAt, recipientID)
+	return err
+}
+
+// Fail records the last attempt and moves the recipient into its final failed
+// state; it will not be claimed again.
+func (j JobModel) Fail(recipientID int64, lastError string) error {
+	query := `UPDATE recipients SET last_error = $1, failed = true, next_attempt_at = NULL, locked_until = NULL
+	WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := j.DB.ExecContext(ctx, query, lastError, recipientID)
+	return err
+}
+
+// Skip moves the recipient into its final skipped state without sending it,
+// recording why in lastError; it will not be claimed again.
+func (j JobModel) Skip(recipientID int64, reason, lastError string) error {
+	query := `UPDATE recipients SET skipped = $1, last_error = $2, next_attempt_at = NULL, locked_until = NULL
+	WHERE id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := j.DB.ExecContext(ctx, query, reason, lastError, recipientID)
+	return err
+}
+
+// Complete marks the job completed once every recipient has been sent, has
+// failed for good or was skipped. It is a no-op while there is still work outstanding.
+func (j JobModel) Complete(id int64) error {
+	query := `UPDATE jobs SET status = $1, completed_at = NOW()
+	WHERE id = $2 AND status <> $1
+	AND NOT EXISTS (SELECT 1 FROM recipients WHERE recipients.email_id = jobs.email_id AND recipients.status = false AND recipients.failed = false AND recipients.skipped = '')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := j.DB.ExecContext(ctx, query, JobCompleted, id)
+	return err
+}
@@ -0,0 +1,221 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+var ErrDuplicateListName = errors.New("duplicate list name")
+
+// List is a named audience of contacts that can be sent to through
+// list_ids.
+type List struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Members     int       `json:"members"`
+}
+
+func ValidateList(v *validator.Validator, l *List) {
+	v.Check(l.Name != "", "name", "must be provided")
+	v.Check(len(l.Name) <= 255, "name", "must not be more than 255 bytes long")
+	v.Check(len(l.Description) <= 1000, "description", "must not be more than 1000 bytes long")
+}
+
+type ListModel struct {
+	DB *sql.DB
+}
+
+func (m ListModel) Insert(l *List) error {
+	query := `INSERT INTO lists (name, description) VALUES ($1, $2) RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, l.Name, l.Description).Scan(&l.ID, &l.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "lists_name_key"`:
+			return ErrDuplicateListName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+const listColumns = `lists.id, lists.created_at, lists.name, lists.description,
+	(SELECT COUNT(*) FROM list_members WHERE list_members.list_id = lists.id)`
+
+func (m ListModel) Get(id int64) (*List, error) {
+	query := `SELECT ` + listColumns + ` FROM lists WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var l List
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&l.ID, &l.CreatedAt, &l.Name, &l.Description, &l.Members)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &l, nil
+}
+
+func (m ListModel) GetAll() ([]*List, error) {
+	query := `SELECT ` + listColumns + ` FROM lists ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*List{}
+
+	for rows.Next() {
+		var l List
+		err = rows.Scan(&l.ID, &l.CreatedAt, &l.Name, &l.Description, &l.Members)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, &l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+func (m ListModel) Update(l *List) error {
+	query := `UPDATE lists SET name = $1, description = $2 WHERE id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, l.Name, l.Description, l.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "lists_name_key"`:
+			return ErrDuplicateListName
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete removes the list and its memberships, but not the contacts.
+func (m ListModel) Delete(id int64) error {
+	query := `DELETE FROM lists WHERE id = $1`
+
+	return m.deleteOne(query, id)
+}
+
+func (m ListModel) RemoveMember(listID, contactID int64) error {
+	query := `DELETE FROM list_members WHERE list_id = $1 AND contact_id = $2`
+
+	return m.deleteOne(query, listID, contactID)
+}
+
+func (m ListModel) deleteOne(query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Missing returns the ids that don't belong to any list.
+func (m ListModel) Missing(ids []int64) ([]int64, error) {
+	query := `SELECT COALESCE(array_agg(ids.id), '{}') FROM unnest($1::bigint[]) AS ids(id)
+	LEFT JOIN lists ON lists.id = ids.id
+	WHERE lists.id IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var missing []int64
+
+	err := m.DB.QueryRowContext(ctx, query, pq.Array(ids)).Scan(pq.Array(&missing))
+	if err != nil {
+		return nil, err
+	}
+
+	return missing, nil
+}
+
+// Addresses returns every contact that is a member of at least one of the
+// lists, once, with its merge fields.
+func (m ListModel) Addresses(ids []int64) ([]Address, error) {
+	query := `SELECT contacts.address, contacts.name, contacts.attributes FROM contacts
+	WHERE EXISTS (SELECT 1 FROM list_members WHERE list_members.contact_id = contacts.id AND list_members.list_id = ANY($1))
+	ORDER BY contacts.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []Address
+
+	for rows.Next() {
+		var c Contact
+		err = rows.Scan(&c.Address, &c.Name, &c.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, Address{Email: c.Address, Data: c.Data()})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
@@ -7,14 +7,41 @@ import (
 
 var (
 	ErrRecordNotFound = errors.New("record not found")
+	ErrEditConflict   = errors.New("edit conflict")
 )
 
 type Models struct {
-	Emails EmailModel
+	APIKeys      APIKeyModel
+	Attachments  AttachmentModel
+	Clicks       ClickModel
+	Contacts     ContactModel
+	Imports      ContactImportModel
+	Emails       EmailModel
+	Events       EventModel
+	Idempotency  IdempotencyModel
+	Jobs         JobModel
+	Lists        ListModel
+	Stats        StatsModel
+	Suppressions SuppressionModel
+	Templates    TemplateModel
+	Topics       TopicModel
 }
 
 func NewModel(db *sql.DB) Models {
 	return Models{
-		Emails: EmailModel{DB: db},
+		APIKeys:      APIKeyModel{DB: db},
+		Attachments:  AttachmentModel{DB: db},
+		Clicks:       ClickModel{DB: db},
+		Contacts:     ContactModel{DB: db},
+		Imports:      ContactImportModel{DB: db},
+		Emails:       EmailModel{DB: db},
+		Events:       EventModel{DB: db},
+		Idempotency:  IdempotencyModel{DB: db},
+		Jobs:         JobModel{DB: db},
+		Lists:        ListModel{DB: db},
+		Stats:        StatsModel{DB: db},
+		Suppressions: SuppressionModel{DB: db},
+		Templates:    TemplateModel{DB: db},
+		Topics:       TopicModel{DB: db},
 	}
 }
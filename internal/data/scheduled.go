@@ -0,0 +1,180 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+const (
+	EmailScheduled = "scheduled"
+	EmailQueued    = "queued"
+	EmailCancelled = "cancelled"
+)
+
+// ErrNotScheduled is returned when rescheduling or cancelling an email that
+// has already been queued for delivery or was cancelled before.
+var ErrNotScheduled = errors.New("email is not scheduled")
+
+// ScheduledEmail is an email waiting for its send_at time.
+type ScheduledEmail struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	SendAt     time.Time `json:"send_at"`
+	Sender     string    `json:"sender"`
+	Subject    string    `json:"subject"`
+	TemplateID int64     `json:"template_id,omitempty"`
+	JobID      int64     `json:"job_id"`
+	Recipients int       `json:"recipients"`
+}
+
+func ValidateSendAt(v *validator.Validator, sendAt time.Time) {
+	v.Check(sendAt.After(time.Now()), "send_at", "must be in the future")
+}
+
+const scheduledColumns = `emails.id, emails.created_at, emails.send_at, emails.sender, emails.subject, COALESCE(emails.template_id, 0), jobs.id,
+	(SELECT COUNT(*) FROM recipients WHERE recipients.email_id = emails.id)`
+
+func (s *ScheduledEmail) scanDest() []any {
+	return []any{&s.ID, &s.CreatedAt, &s.SendAt, &s.Sender, &s.Subject, &s.TemplateID, &s.JobID, &s.Recipients}
+}
+
+func (e EmailModel) GetAllScheduled(filters Filters) ([]*ScheduledEmail, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), %s
+	FROM emails JOIN jobs ON jobs.email_id = emails.id
+	WHERE emails.state = $1
+	ORDER BY emails.%s %s, emails.id ASC
+	LIMIT $2 OFFSET $3`, scheduledColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := e.DB.QueryContext(ctx, query, EmailScheduled, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	emails := []*ScheduledEmail{}
+
+	for rows.Next() {
+		var s ScheduledEmail
+		err = rows.Scan(append([]any{&totalRecords}, s.scanDest()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		emails = append(emails, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return emails, metadata, nil
+}
+
+// Reschedule moves the send time of an email that is still scheduled.
+func (e EmailModel) Reschedule(id int64, sendAt time.Time) (*ScheduledEmail, error) {
+	query := `UPDATE emails SET send_at = $1 FROM jobs
+	WHERE emails.id = $2 AND emails.state = $3 AND jobs.email_id = emails.id
+	RETURNING ` + scheduledColumns
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var s ScheduledEmail
+
+	err := e.DB.QueryRowContext(ctx, query, sendAt, id, EmailScheduled).Scan(s.scanDest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, e.notScheduled(id)
+		default:
+			return nil, err
+		}
+	}
+
+	return &s, nil
+}
+
+// CancelScheduled cancels an email before it goes out. Its job is marked
+// cancelled too, so none of its recipients are ever claimed.
+func (e EmailModel) CancelScheduled(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE emails SET state = $1 WHERE id = $2 AND state = $3`
+
+	result, err := tx.ExecContext(ctx, query, EmailCancelled, id, EmailScheduled)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return e.notScheduled(id)
+	}
+
+	query = `UPDATE jobs SET status = $1, completed_at = NOW() WHERE email_id = $2`
+
+	_, err = tx.ExecContext(ctx, query, JobCancelled, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// notScheduled tells apart an email that does not exist from one that is no
+// longer scheduled.
+func (e EmailModel) notScheduled(id int64) error {
+	_, err := e.Get(id)
+	if err != nil {
+		return err
+	}
+	return ErrNotScheduled
+}
+
+// DispatchDue hands every scheduled email whose send_at has passed over to
+// the workers and returns how many were queued. Emails are locked with SKIP
+// LOCKED, so concurrent schedulers and cancellations never queue one twice.
+func (j JobModel) DispatchDue(now time.Time) (int64, error) {
+	query := `WITH due AS (
+		UPDATE emails SET state = $1
+		WHERE id IN (
+			SELECT id FROM emails WHERE state = $2 AND send_at <= $3
+			ORDER BY send_at
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	)
+	UPDATE jobs SET status = $4 FROM due
+	WHERE jobs.email_id = due.id AND jobs.status = $5`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := j.DB.ExecContext(ctx, query, EmailQueued, EmailScheduled, now, JobQueued, JobScheduled)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
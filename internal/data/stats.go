@@ -0,0 +1,177 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+// StatsFilters selects the emails that statistics are computed over. Zero
+// values mean "no filter".
+type StatsFilters struct {
+	EmailID  int64
+	Sender   string
+	Since    time.Time
+	Until    time.Time
+	Interval string
+}
+
+func ValidateStatsFilters(v *validator.Validator, f StatsFilters) {
+	v.Check(validator.In(f.Interval, "hour", "day"), "interval", "must be hour or day")
+	v.Check(f.Since.IsZero() || f.Until.IsZero() || f.Since.Before(f.Until), "until", "must be later than since")
+}
+
+// where returns the WHERE clause shared by the statistics queries, with its
+// arguments starting at $1. Cancelled emails are never counted.
+func (f StatsFilters) where() (string, []any) {
+	clause := `WHERE emails.state <> 'cancelled'
+	AND (emails.id = $1 OR $1 = 0)
+	AND (LOWER(emails.sender) = LOWER($2) OR $2 = '')
+	AND (emails.created_at >= $3 OR $3::timestamptz IS NULL)
+	AND (emails.created_at < $4 OR $4::timestamptz IS NULL)`
+
+	return clause, []any{f.EmailID, f.Sender, nullTime(f.Since), nullTime(f.Until)}
+}
+
+// Stats are the engagement totals of a set of emails. Opens only count
+// people: machine opens by image proxies are reported separately.
+type Stats struct {
+	Recipients   int     `json:"recipients"`
+	Queued       int     `json:"queued"`
+	Sent         int     `json:"sent"`
+	Failed       int     `json:"failed"`
+	Skipped      int     `json:"skipped"`
+	Bounced      int     `json:"bounced"`
+	SoftBounced  int     `json:"soft_bounced"`
+	Opened       int     `json:"opened"`
+	Clicked      int     `json:"clicked"`
+	Opens        int     `json:"opens"`
+	MachineOpens int     `json:"machine_opens"`
+	Clicks       int     `json:"clicks"`
+	OpenRate     float64 `json:"open_rate"`
+	ClickRate    float64 `json:"click_rate"`
+
+	TimeToFirstOpen *OpenPercentiles `json:"time_to_first_open"`
+
+	Series []*StatsBucket `json:"series"`
+}
+
+// OpenPercentiles is the time between sending and the first open, in
+// seconds.
+type OpenPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// StatsBucket holds what happened within one hour or day.
+type StatsBucket struct {
+	Time   time.Time `json:"time"`
+	Sent   int       `json:"sent"`
+	Opens  int       `json:"opens"`
+	Clicks int       `json:"clicks"`
+}
+
+type StatsModel struct {
+	DB *sql.DB
+}
+
+// Get computes the totals, open percentiles and time series for the emails
+// matched by filters.
+func (m StatsModel) Get(filters StatsFilters) (*Stats, error) {
+	where, args := filters.where()
+
+	query := `SELECT COUNT(*),
+		COUNT(*) FILTER (WHERE NOT recipients.status AND NOT recipients.failed AND recipients.skipped = ''),
+		COUNT(*) FILTER (WHERE recipients.status),
+		COUNT(*) FILTER (WHERE recipients.failed),
+		COUNT(*) FILTER (WHERE recipients.skipped <> ''),
+		COUNT(*) FILTER (WHERE recipients.bounce_type = 'hard'),
+		COUNT(*) FILTER (WHERE recipients.bounce_type = 'soft'),
+		COUNT(*) FILTER (WHERE recipients.opened),
+		COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM clicks WHERE clicks.recipient_id = recipients.id)),
+		COALESCE(SUM(recipients.open_count), 0),
+		COALESCE(SUM(recipients.machine_open_count), 0),
+		(SELECT COUNT(*) FROM clicks JOIN recipients ON recipients.id = clicks.recipient_id JOIN emails ON emails.id = recipients.email_id ` + where + `),
+		COALESCE(COUNT(*) FILTER (WHERE recipients.opened)::float / NULLIF(COUNT(*) FILTER (WHERE recipients.status), 0), 0),
+		COALESCE(COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM clicks WHERE clicks.recipient_id = recipients.id))::float
+			/ NULLIF(COUNT(*) FILTER (WHERE recipients.status), 0), 0),
+		percentile_cont(ARRAY[0.5, 0.9, 0.99]) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM recipients.first_opened_at - recipients.sent_time))
+			FILTER (WHERE recipients.status AND recipients.first_opened_at IS NOT NULL)
+	FROM recipients JOIN emails ON emails.id = recipients.email_id
+	` + where
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		stats       Stats
+		percentiles pq.Float64Array
+	)
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&stats.Recipients, &stats.Queued, &stats.Sent, &stats.Failed, &stats.Skipped, &stats.Bounced, &stats.SoftBounced, &stats.Opened, &stats.Clicked,
+		&stats.Opens, &stats.MachineOpens, &stats.Clicks, &stats.OpenRate, &stats.ClickRate, &percentiles)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(percentiles) == 3 {
+		stats.TimeToFirstOpen = &OpenPercentiles{P50: percentiles[0], P90: percentiles[1], P99: percentiles[2]}
+	}
+
+	stats.Series, err = m.series(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// series buckets sends, human opens and clicks by hour or day in UTC.
+func (m StatsModel) series(ctx context.Context, filters StatsFilters) ([]*StatsBucket, error) {
+	where, args := filters.where()
+
+	query := `SELECT bucket, SUM(sent), SUM(opens), SUM(clicks) FROM (
+		SELECT date_trunc($5, recipients.sent_time, 'UTC') AS bucket, 1 AS sent, 0 AS opens, 0 AS clicks
+		FROM recipients JOIN emails ON emails.id = recipients.email_id
+		` + where + ` AND recipients.status
+		UNION ALL
+		SELECT date_trunc($5, email_events.created_at, 'UTC'), 0, 1, 0
+		FROM email_events JOIN recipients ON recipients.id = email_events.recipient_id JOIN emails ON emails.id = recipients.email_id
+		` + where + ` AND email_events.type = 'open' AND NOT email_events.machine
+		UNION ALL
+		SELECT date_trunc($5, clicks.clicked_at, 'UTC'), 0, 0, 1
+		FROM clicks JOIN recipients ON recipients.id = clicks.recipient_id JOIN emails ON emails.id = recipients.email_id
+		` + where + `
+	) AS events
+	GROUP BY bucket
+	ORDER BY bucket`
+
+	args = append(args, filters.Interval)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []*StatsBucket{}
+
+	for rows.Next() {
+		var b StatsBucket
+		err = rows.Scan(&b.Time, &b.Sent, &b.Opens, &b.Clicks)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, &b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
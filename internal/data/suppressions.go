@@ -0,0 +1,200 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+var ErrDuplicateSuppression = errors.New("duplicate suppression")
+
+const (
+	SuppressionHardBounce   = "hard_bounce"
+	SuppressionUnsubscribed = "unsubscribed"
+	SuppressionComplaint    = "complaint"
+	SuppressionManual       = "manual"
+)
+
+var SuppressionReasons = []string{SuppressionHardBounce, SuppressionUnsubscribed, SuppressionComplaint, SuppressionManual}
+
+// Where a suppression came from.
+const (
+	SourceBounce      = "bounce"
+	SourceUnsubscribe = "unsubscribe"
+	SourceAPI         = "api"
+	SourceImport      = "import"
+)
+
+// Suppression is an address that must not be sent to any more. Addresses are
+// stored in lower case and matched case-insensitively.
+type Suppression struct {
+	ID        int64     `json:"id"`
+	Address   string    `json:"address"`
+	Reason    string    `json:"reason"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func ValidateSuppression(v *validator.Validator, s *Suppression) {
+	v.Check(s.Address != "", "address", "must be provided")
+	v.Check(len(s.Address) <= 255, "address", "must not be more than 255 bytes long")
+	v.Check(validator.Matches(s.Address, validator.EmailRx), "address", "must be a valid email address")
+	v.Check(validator.PermittedValue(s.Reason, SuppressionReasons...), "reason", "must be one of "+strings.Join(SuppressionReasons, ", "))
+}
+
+type SuppressionModel struct {
+	DB *sql.DB
+}
+
+func (m SuppressionModel) Insert(s *Suppression) error {
+	query := `INSERT INTO suppressions (address, reason, source) VALUES (LOWER($1), $2, $3)
+	RETURNING id, address, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, s.Address, s.Reason, s.Source).Scan(&s.ID, &s.Address, &s.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "suppressions_address_key"`:
+			return ErrDuplicateSuppression
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Suppress adds address unless it is suppressed already, in which case the
+// existing entry is kept. It is used by the bounce and unsubscribe paths.
+func (m SuppressionModel) Suppress(address, reason, source string) error {
+	_, err := m.Import([]string{address}, reason, source)
+	return err
+}
+
+// Import adds every address that is not suppressed yet and returns how many
+// were added.
+func (m SuppressionModel) Import(addresses []string, reason, source string) (int64, error) {
+	query := `INSERT INTO suppressions (address, reason, source)
+	SELECT DISTINCT LOWER(unnest($1::text[])), $2, $3
+	ON CONFLICT (address) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, pq.Array(addresses), reason, source)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// SuppressionFilters narrows down the suppression list. Zero values mean "no
+// filter".
+type SuppressionFilters struct {
+	Address string
+	Reason  string
+	Filters
+}
+
+func (m SuppressionModel) GetAll(filters SuppressionFilters) ([]*Suppression, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id, address, reason, source, created_at
+	FROM suppressions
+	WHERE (address LIKE '%%' || LOWER($1) || '%%' OR $1 = '')
+	AND (reason = $2 OR $2 = '')
+	ORDER BY %s %s, id ASC
+	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.Address, filters.Reason, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	suppressions := []*Suppression{}
+
+	for rows.Next() {
+		var s Suppression
+		err = rows.Scan(&totalRecords, &s.ID, &s.Address, &s.Reason, &s.Source, &s.CreatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		suppressions = append(suppressions, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return suppressions, metadata, nil
+}
+
+// Check returns the suppressions among addresses, keyed by lower-cased
+// address.
+func (m SuppressionModel) Check(addresses []string) (map[string]*Suppression, error) {
+	query := `SELECT id, address, reason, source, created_at FROM suppressions
+	WHERE address = ANY(SELECT LOWER(unnest($1::text[])))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(addresses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppressed := make(map[string]*Suppression)
+
+	for rows.Next() {
+		var s Suppression
+		err = rows.Scan(&s.ID, &s.Address, &s.Reason, &s.Source, &s.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		suppressed[s.Address] = &s
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suppressed, nil
+}
+
+func (m SuppressionModel) Delete(address string) error {
+	query := `DELETE FROM suppressions WHERE address = LOWER($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, address)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
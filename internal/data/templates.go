@@ -0,0 +1,240 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+var (
+	ErrDuplicateTemplateName = errors.New("duplicate template name")
+	ErrTemplateInUse         = errors.New("template in use")
+)
+
+type Template struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	Subject   string    `json:"subject"`
+	PlainBody string    `json:"plain_body"`
+	HTMLBody  string    `json:"html_body"`
+}
+
+func ValidateTemplate(v *validator.Validator, t *Template) {
+	v.Check(t.Name != "", "name", "must be provided")
+	v.Check(len(t.Name) <= 255, "name", "must not be more than 255 bytes long")
+	v.Check(t.Subject != "", "subject", "must be provided")
+	v.Check(t.HTMLBody != "", "html_body", "must be provided")
+}
+
+type TemplateModel struct {
+	DB *sql.DB
+}
+
+// Insert creates the template together with its first version.
+func (m TemplateModel) Insert(t *Template) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO templates (name) VALUES ($1) RETURNING id, created_at, updated_at, version`
+
+	err = tx.QueryRowContext(ctx, query, t.Name).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt, &t.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "templates_name_key"`:
+			return ErrDuplicateTemplateName
+		default:
+			return err
+		}
+	}
+
+	err = insertTemplateVersion(ctx, tx, t)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertTemplateVersion(ctx context.Context, tx *sql.Tx, t *Template) error {
+	query := `INSERT INTO template_versions (template_id, version, subject, plain_body, html_body)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := tx.ExecContext(ctx, query, t.ID, t.Version, t.Subject, t.PlainBody, t.HTMLBody)
+	return err
+}
+
+const templateColumns = `templates.id, templates.created_at, templates.updated_at, templates.name, template_versions.version,
+	template_versions.subject, template_versions.plain_body, template_versions.html_body`
+
+func (t *Template) scanDest() []any {
+	return []any{&t.ID, &t.CreatedAt, &t.UpdatedAt, &t.Name, &t.Version, &t.Subject, &t.PlainBody, &t.HTMLBody}
+}
+
+func (m TemplateModel) getOne(query string, args ...any) (*Template, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var t Template
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(t.scanDest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+func (m TemplateModel) getMany(query string, args ...any) ([]*Template, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*Template{}
+
+	for rows.Next() {
+		var t Template
+		err = rows.Scan(t.scanDest()...)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// Get returns the current version of a template.
+func (m TemplateModel) Get(id int64) (*Template, error) {
+	query := `SELECT ` + templateColumns + `
+	FROM templates JOIN template_versions ON template_versions.template_id = templates.id AND template_versions.version = templates.version
+	WHERE templates.id = $1`
+
+	return m.getOne(query, id)
+}
+
+// GetByName returns the current version of a template.
+func (m TemplateModel) GetByName(name string) (*Template, error) {
+	query := `SELECT ` + templateColumns + `
+	FROM templates JOIN template_versions ON template_versions.template_id = templates.id AND template_versions.version = templates.version
+	WHERE templates.name = $1`
+
+	return m.getOne(query, name)
+}
+
+// GetVersion returns a specific, possibly superseded, version of a template.
+func (m TemplateModel) GetVersion(id int64, version int) (*Template, error) {
+	query := `SELECT ` + templateColumns + `
+	FROM templates JOIN template_versions ON template_versions.template_id = templates.id
+	WHERE templates.id = $1 AND template_versions.version = $2`
+
+	return m.getOne(query, id, version)
+}
+
+func (m TemplateModel) GetAll() ([]*Template, error) {
+	query := `SELECT ` + templateColumns + `
+	FROM templates JOIN template_versions ON template_versions.template_id = templates.id AND template_versions.version = templates.version
+	ORDER BY templates.name`
+
+	return m.getMany(query)
+}
+
+// GetVersions returns the version history of a template, newest first.
+func (m TemplateModel) GetVersions(id int64) ([]*Template, error) {
+	query := `SELECT ` + templateColumns + `
+	FROM templates JOIN template_versions ON template_versions.template_id = templates.id
+	WHERE templates.id = $1
+	ORDER BY template_versions.version DESC`
+
+	return m.getMany(query, id)
+}
+
+// Update stores t as a new version of the template. t.Version must be the
+// version that was read, so that two concurrent edits cannot silently
+// overwrite each other.
+func (m TemplateModel) Update(t *Template) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE templates SET name = $1, version = version + 1, updated_at = NOW()
+	WHERE id = $2 AND version = $3
+	RETURNING version, updated_at`
+
+	err = tx.QueryRowContext(ctx, query, t.Name, t.ID, t.Version).Scan(&t.Version, &t.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "templates_name_key"`:
+			return ErrDuplicateTemplateName
+		default:
+			return err
+		}
+	}
+
+	err = insertTemplateVersion(ctx, tx, t)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m TemplateModel) Delete(id int64) error {
+	query := `DELETE FROM templates WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: update or delete on table "templates" violates foreign key constraint "emails_template_id_fkey" on table "emails"`:
+			return ErrTemplateInUse
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
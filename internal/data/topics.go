@@ -0,0 +1,220 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mayura-andrew/email-client/internal/validator"
+)
+
+var ErrDuplicateTopicName = errors.New("duplicate topic name")
+
+var topicNameRx = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Topic is a kind of email, such as announcements or reminders, that
+// recipients can opt out of on its own. Everybody is subscribed to every
+// topic until they opt out.
+type Topic struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+}
+
+func ValidateTopic(v *validator.Validator, t *Topic) {
+	v.Check(t.Name != "", "name", "must be provided")
+	v.Check(len(t.Name) <= 50, "name", "must not be more than 50 bytes long")
+	v.Check(validator.Matches(t.Name, topicNameRx), "name", "must only contain lower case letters, digits and hyphens")
+	v.Check(len(t.Description) <= 500, "description", "must not be more than 500 bytes long")
+}
+
+// TopicPreference is whether an address receives emails of a topic.
+type TopicPreference struct {
+	Topic
+	Subscribed bool `json:"subscribed"`
+}
+
+type TopicModel struct {
+	DB *sql.DB
+}
+
+func (m TopicModel) Insert(t *Topic) error {
+	query := `INSERT INTO topics (name, description) VALUES ($1, $2) RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, t.Name, t.Description).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "topics_name_key"`:
+			return ErrDuplicateTopicName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m TopicModel) GetByName(name string) (*Topic, error) {
+	query := `SELECT id, created_at, name, description FROM topics WHERE name = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var t Topic
+
+	err := m.DB.QueryRowContext(ctx, query, name).Scan(&t.ID, &t.CreatedAt, &t.Name, &t.Description)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+func (m TopicModel) GetAll() ([]*Topic, error) {
+	query := `SELECT id, created_at, name, description FROM topics ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := []*Topic{}
+
+	for rows.Next() {
+		var t Topic
+		err = rows.Scan(&t.ID, &t.CreatedAt, &t.Name, &t.Description)
+		if err != nil {
+			return nil, err
+		}
+		topics = append(topics, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return topics, nil
+}
+
+// Delete removes a topic together with the preferences for it. Emails that
+// were sent with the topic keep no reference to it.
+func (m TopicModel) Delete(name string) error {
+	query := `DELETE FROM topics WHERE name = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, name)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetPreferences returns every topic with whether address is subscribed to
+// it.
+func (m TopicModel) GetPreferences(address string) ([]*TopicPreference, error) {
+	query := `SELECT topics.id, topics.created_at, topics.name, topics.description, COALESCE(topic_preferences.subscribed, true)
+	FROM topics LEFT JOIN topic_preferences ON topic_preferences.topic_id = topics.id AND topic_preferences.address = LOWER($1)
+	ORDER BY topics.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preferences := []*TopicPreference{}
+
+	for rows.Next() {
+		var p TopicPreference
+		err = rows.Scan(&p.ID, &p.CreatedAt, &p.Name, &p.Description, &p.Subscribed)
+		if err != nil {
+			return nil, err
+		}
+		preferences = append(preferences, &p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+// SetPreferences subscribes address to the topics in subscribed and opts it
+// out of every other topic.
+func (m TopicModel) SetPreferences(address string, subscribed []int64) error {
+	query := `INSERT INTO topic_preferences (address, topic_id, subscribed)
+	SELECT LOWER($1), topics.id, COALESCE(topics.id = ANY($2), false) FROM topics
+	ON CONFLICT (address, topic_id) DO UPDATE SET subscribed = EXCLUDED.subscribed, updated_at = NOW()
+	WHERE topic_preferences.subscribed <> EXCLUDED.subscribed`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, address, pq.Array(subscribed))
+	return err
+}
+
+// OptedOut returns the lower-cased addresses among addresses that opted out
+// of the topic.
+func (m TopicModel) OptedOut(topicID int64, addresses []string) (map[string]bool, error) {
+	query := `SELECT address FROM topic_preferences
+	WHERE topic_id = $1 AND NOT subscribed AND address = ANY(SELECT LOWER(unnest($2::text[])))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, topicID, pq.Array(addresses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	optedOut := make(map[string]bool)
+
+	for rows.Next() {
+		var address string
+		err = rows.Scan(&address)
+		if err != nil {
+			return nil, err
+		}
+		optedOut[address] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return optedOut, nil
+}
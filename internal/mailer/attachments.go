@@ -0,0 +1,78 @@
+package mailer
+
+import (
+	"sync"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+// attachmentCacheBytes bounds the attachment contents kept in memory: room
+// for a few emails with the full attachment allowance each.
+const attachmentCacheBytes = 4 * data.MaxTotalAttachmentSize
+
+// attachmentCache holds the attachments of recently sent emails, so that they
+// are loaded once per email rather than once per recipient. Workers claim
+// recipients in order, so the oldest email is evicted first once the cache
+// grows past maxBytes.
+type attachmentCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	order    []int64
+	emails   map[int64][]*data.Attachment
+}
+
+func newAttachmentCache(maxBytes int) *attachmentCache {
+	return &attachmentCache{
+		maxBytes: maxBytes,
+		emails:   make(map[int64][]*data.Attachment),
+	}
+}
+
+func (c *attachmentCache) get(emailID int64, load func(emailID int64) ([]*data.Attachment, error)) ([]*data.Attachment, error) {
+	c.mu.Lock()
+	attachments, ok := c.emails[emailID]
+	c.mu.Unlock()
+
+	if ok {
+		return attachments, nil
+	}
+
+	attachments, err := load(emailID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.emails[emailID]; !ok {
+		c.emails[emailID] = attachments
+		c.order = append(c.order, emailID)
+		c.size += contentSize(attachments)
+
+		for c.size > c.maxBytes && len(c.order) > 1 {
+			oldest := c.order[0]
+			c.order = c.order[1:]
+			c.size -= contentSize(c.emails[oldest])
+			delete(c.emails, oldest)
+		}
+	}
+
+	return attachments, nil
+}
+
+func contentSize(attachments []*data.Attachment) int {
+	size := 0
+	for _, a := range attachments {
+		size += len(a.Content)
+	}
+	return size
+}
+
+// Attachments returns the attachments of email emailID with their contents,
+// calling load to fetch them from the database on a cache miss. The
+// attachments are shared between callers and must not be modified.
+func (m Mailer) Attachments(emailID int64, load func(emailID int64) ([]*data.Attachment, error)) ([]*data.Attachment, error) {
+	return m.attachments.get(emailID, load)
+}
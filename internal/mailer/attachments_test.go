@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+func TestAttachmentCache(t *testing.T) {
+	loads := make(map[int64]int)
+	fail := false
+
+	load := func(emailID int64) ([]*data.Attachment, error) {
+		loads[emailID]++
+		if fail {
+			return nil, errors.New("connection reset")
+		}
+		return []*data.Attachment{{Filename: "a.pdf", Content: make([]byte, 40)}}, nil
+	}
+
+	c := newAttachmentCache(100)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.get(1, load); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loads[1] != 1 {
+		t.Errorf("email 1 loaded %d times, want once", loads[1])
+	}
+
+	// Emails 2 and 3 take the cache past 100 bytes, which evicts email 1.
+	c.get(2, load)
+	c.get(3, load)
+	c.get(3, load)
+	c.get(1, load)
+
+	if loads[1] != 2 || loads[2] != 1 || loads[3] != 1 {
+		t.Errorf("loads = %v, want email 1 loaded again after eviction and the others once", loads)
+	}
+	if c.size > c.maxBytes {
+		t.Errorf("size = %d, want at most %d", c.size, c.maxBytes)
+	}
+
+	fail = true
+	if _, err := c.get(4, load); err == nil {
+		t.Fatal("get returned no error for a failed load")
+	}
+
+	fail = false
+	if _, err := c.get(4, load); err != nil {
+		t.Fatal(err)
+	}
+	if loads[4] != 2 {
+		t.Errorf("email 4 loaded %d times, want the failed load retried", loads[4])
+	}
+}
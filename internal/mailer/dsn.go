@@ -0,0 +1,178 @@
+package mailer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+// ErrNotDSN is returned by ParseDSN for messages that are not RFC 3464
+// delivery status notifications.
+var ErrNotDSN = errors.New("message is not a delivery status notification")
+
+// DSN is a parsed delivery status notification. MessageID is the Message-ID
+// of the original message, taken from the returned message or headers.
+type DSN struct {
+	MessageID  string
+	Recipients []DSNRecipient
+}
+
+// DSNRecipient is one per-recipient block of a delivery status notification.
+type DSNRecipient struct {
+	FinalRecipient string
+	Action         string
+	Status         string
+	DiagnosticCode string
+}
+
+// BounceType classifies the block: a failed action with a 5.x.x status is a
+// hard bounce, a failed action with a 4.x.x status or a delayed action is a
+// soft bounce. Successful actions return an empty string.
+func (r DSNRecipient) BounceType() string {
+	switch r.Action {
+	case "failed":
+		if strings.HasPrefix(r.Status, "4") {
+			return data.BounceSoft
+		}
+		return data.BounceHard
+	case "delayed":
+		return data.BounceSoft
+	}
+	return ""
+}
+
+// Bounce returns the most severe bounce reported by the notification, or nil
+// if every recipient was delivered.
+func (d *DSN) Bounce() *DSNRecipient {
+	var bounce *DSNRecipient
+
+	for i, r := range d.Recipients {
+		switch r.BounceType() {
+		case data.BounceHard:
+			return &d.Recipients[i]
+		case data.BounceSoft:
+			if bounce == nil {
+				bounce = &d.Recipients[i]
+			}
+		}
+	}
+	return bounce
+}
+
+// ParseDSN parses a raw multipart/report message with a delivery-status
+// report type.
+func ParseDSN(r io.Reader) (*DSN, error) {
+	msg, err := netmail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "delivery-status") {
+		return nil, ErrNotDSN
+	}
+
+	dsn := &DSN{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body := decodePart(part)
+
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			dsn.Recipients, err = parseDeliveryStatus(body)
+			if err != nil {
+				return nil, err
+			}
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			header, err := textproto.NewReader(bufio.NewReader(body)).ReadMIMEHeader()
+			if err != nil && len(header) == 0 {
+				return nil, err
+			}
+			dsn.MessageID = header.Get("Message-Id")
+		}
+	}
+
+	if dsn.Recipients == nil {
+		return nil, ErrNotDSN
+	}
+
+	return dsn, nil
+}
+
+// decodePart undoes a base64 transfer encoding; the multipart reader already
+// handles quoted-printable.
+func decodePart(part *multipart.Part) io.Reader {
+	if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+		return base64.NewDecoder(base64.StdEncoding, part)
+	}
+	return part
+}
+
+// parseDeliveryStatus reads the per-message block, which is skipped, followed
+// by one block per recipient.
+func parseDeliveryStatus(r io.Reader) ([]DSNRecipient, error) {
+	tp := textproto.NewReader(bufio.NewReader(r))
+
+	_, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, ErrNotDSN
+	}
+
+	recipients := []DSNRecipient{}
+
+	for {
+		fields, err := tp.ReadMIMEHeader()
+		if len(fields) > 0 {
+			recipients = append(recipients, DSNRecipient{
+				FinalRecipient: typedValue(fields.Get("Final-Recipient")),
+				Action:         strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+				Status:         strings.TrimSpace(fields.Get("Status")),
+				DiagnosticCode: typedValue(fields.Get("Diagnostic-Code")),
+			})
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	return recipients, nil
+}
+
+// typedValue strips the type prefix from fields such as
+// "rfc822; alice@example.com" and "smtp; 550 5.1.1 User unknown".
+func typedValue(s string) string {
+	if _, value, ok := strings.Cut(s, ";"); ok {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(s)
+}
+
+// MessageIDToken returns the tracking token embedded in a Message-ID set by
+// Send, or an empty string.
+func MessageIDToken(messageID string) string {
+	id := strings.Trim(strings.TrimSpace(messageID), "<>")
+
+	token, _, ok := strings.Cut(id, "@")
+	if !ok {
+		return ""
+	}
+	return token
+}
@@ -0,0 +1,177 @@
+package mailer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		file       string
+		messageID  string
+		recipient  string
+		action     string
+		status     string
+		diagnostic string
+		bounceType string
+	}{
+		{
+			file:       "postfix_failed.eml",
+			messageID:  "<k3n9QzX1b7@example.com>",
+			recipient:  "alice@example.net",
+			action:     "failed",
+			status:     "5.1.1",
+			diagnostic: "550 5.1.1 <alice@example.net>: Recipient address rejected: User unknown in virtual mailbox table",
+			bounceType: data.BounceHard,
+		},
+		{
+			file:       "postfix_delayed.eml",
+			messageID:  "<Vb2pL0s8Qe@example.com>",
+			recipient:  "bob@example.org",
+			action:     "delayed",
+			status:     "4.4.1",
+			diagnostic: "connect to mx.example.org[198.51.100.7]:25: Connection timed out",
+			bounceType: data.BounceSoft,
+		},
+		{
+			file:       "exim_failed.eml",
+			messageID:  "<Ru7cM2w4Jd@example.com>",
+			recipient:  "carol@example.net",
+			action:     "failed",
+			status:     "5.0.0",
+			diagnostic: "550 5.2.1 Mailbox disabled",
+			bounceType: data.BounceHard,
+		},
+		{
+			file:       "gmail_failed.eml",
+			messageID:  "<Hq5tN8a2Kc@example.com>",
+			recipient:  "dave@example.com",
+			action:     "failed",
+			status:     "5.1.1",
+			diagnostic: "550-5.1.1 The email account that you tried to reach does not exist.",
+			bounceType: data.BounceHard,
+		},
+		{
+			file:       "base64_soft.eml",
+			messageID:  "<Wz4kP9d1Tf@example.com>",
+			recipient:  "erin@example.org",
+			action:     "failed",
+			status:     "4.2.2",
+			diagnostic: "452 4.2.2 Mailbox full",
+			bounceType: data.BounceSoft,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			dsn, err := ParseDSN(f)
+			if err != nil {
+				t.Fatalf("ParseDSN: %v", err)
+			}
+
+			if dsn.MessageID != tt.messageID {
+				t.Errorf("MessageID = %q, want %q", dsn.MessageID, tt.messageID)
+			}
+
+			bounce := dsn.Bounce()
+			if bounce == nil {
+				t.Fatal("Bounce() = nil")
+			}
+
+			if bounce.FinalRecipient != tt.recipient {
+				t.Errorf("FinalRecipient = %q, want %q", bounce.FinalRecipient, tt.recipient)
+			}
+			if bounce.Action != tt.action {
+				t.Errorf("Action = %q, want %q", bounce.Action, tt.action)
+			}
+			if bounce.Status != tt.status {
+				t.Errorf("Status = %q, want %q", bounce.Status, tt.status)
+			}
+			if !strings.HasPrefix(strings.Join(strings.Fields(bounce.DiagnosticCode), " "), tt.diagnostic) {
+				t.Errorf("DiagnosticCode = %q, want prefix %q", bounce.DiagnosticCode, tt.diagnostic)
+			}
+			if got := bounce.BounceType(); got != tt.bounceType {
+				t.Errorf("BounceType() = %q, want %q", got, tt.bounceType)
+			}
+		})
+	}
+}
+
+func TestParseDSNNotReport(t *testing.T) {
+	msg := "From: alice@example.com\r\nTo: bob@example.com\r\nSubject: Hi\r\nContent-Type: text/plain\r\n\r\nHello\r\n"
+
+	_, err := ParseDSN(strings.NewReader(msg))
+	if !errors.Is(err, ErrNotDSN) {
+		t.Errorf("ParseDSN = %v, want ErrNotDSN", err)
+	}
+}
+
+func TestDSNRecipientBounceType(t *testing.T) {
+	tests := []struct {
+		action string
+		status string
+		want   string
+	}{
+		{"failed", "5.1.1", data.BounceHard},
+		{"failed", "4.2.2", data.BounceSoft},
+		{"delayed", "4.4.1", data.BounceSoft},
+		{"delivered", "2.0.0", ""},
+		{"relayed", "2.0.0", ""},
+		{"expanded", "2.0.0", ""},
+	}
+
+	for _, tt := range tests {
+		r := DSNRecipient{Action: tt.action, Status: tt.status}
+		if got := r.BounceType(); got != tt.want {
+			t.Errorf("BounceType(%s, %s) = %q, want %q", tt.action, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestDSNBounce(t *testing.T) {
+	dsn := &DSN{Recipients: []DSNRecipient{
+		{FinalRecipient: "a@example.com", Action: "delivered", Status: "2.0.0"},
+		{FinalRecipient: "b@example.com", Action: "delayed", Status: "4.4.1"},
+		{FinalRecipient: "c@example.com", Action: "failed", Status: "5.1.1"},
+	}}
+
+	if got := dsn.Bounce(); got == nil || got.FinalRecipient != "c@example.com" {
+		t.Errorf("Bounce() = %+v, want the hard bounce", got)
+	}
+
+	dsn.Recipients = dsn.Recipients[:2]
+	if got := dsn.Bounce(); got == nil || got.FinalRecipient != "b@example.com" {
+		t.Errorf("Bounce() = %+v, want the soft bounce", got)
+	}
+
+	dsn.Recipients = dsn.Recipients[:1]
+	if got := dsn.Bounce(); got != nil {
+		t.Errorf("Bounce() = %+v, want nil", got)
+	}
+}
+
+func TestMessageIDToken(t *testing.T) {
+	tests := map[string]string{
+		"<k3n9QzX1b7@example.com>": "k3n9QzX1b7",
+		" k3n9QzX1b7@example.com ": "k3n9QzX1b7",
+		"<no-domain>":              "",
+		"":                         "",
+	}
+
+	for id, want := range tests {
+		if got := MessageIDToken(id); got != want {
+			t.Errorf("MessageIDToken(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
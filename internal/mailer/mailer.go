@@ -1,151 +1,189 @@
 package mailer
 
 import (
-	"bytes"
-	"fmt"
-	"html/template"
-	"log"
-	"os"
-	"sync"
+	"io"
+	"mime"
+	netmail "net/mail"
+	"strings"
 	"time"
 
 	"github.com/go-mail/mail/v2"
 	"github.com/mayura-andrew/email-client/internal/data"
 )
 
+const templateFile = "./internal/mailer/email_template.tmpl"
 
+// Mailer sends messages over a pool of SMTP connections. It is safe for
+// concurrent use and is meant to be shared by every send path.
 type Mailer struct {
-	dailer *mail.Dialer
-	sender string
-}
-
-type EmailStatus struct {
-	Sent     bool
-	Opened   bool
-	SentTime time.Time
+	pool        *pool
+	templates   *templateCache
+	attachments *attachmentCache
+	tracker     *Tracker
+	sender      string
 }
 
 type EmailData struct {
 	Subject   string
 	Body      string
 	Recipient string
-	EmailId int64
-	URL string
+	EmailId   int64
+	Token     string
+
+	// OpenURL, UnsubscribeURL and PreferencesURL are filled in by Send.
+	// OpenURL is the address of the tracking pixel. Templates may place the
+	// unsubscribe link themselves; otherwise it is appended to both bodies.
+	OpenURL        string
+	UnsubscribeURL string
+	PreferencesURL string
+	URL            string
+	Data           map[string]any
 }
 
-func New(host string, port int, username, password, sender string) Mailer {
+// New returns a Mailer. When tracker is not nil, the links in every HTML body
+// are rewritten to go through the click tracking endpoint.
+func New(host string, port int, username, password, sender string, maxConns int, tracker *Tracker) Mailer {
 	dialer := mail.NewDialer(host, port, username, password)
 	dialer.Timeout = 5 * time.Second
 
 	return Mailer{
-		dailer: dialer,
-		sender: sender,
+		pool:        newPool(dialer.Dial, maxConns),
+		templates:   newTemplateCache(),
+		attachments: newAttachmentCache(attachmentCacheBytes),
+		tracker:     tracker,
+		sender:      sender,
 	}
 }
 
-func NewMail(e data.EmailModel, host string, port int, username, password, sender, subject string, recipients []string, body string) (map[string]*EmailStatus, error) {
-
-	d := mail.NewDialer(host, port, username, password)
-
-	emailStatuses := make(map[string]*EmailStatus)
-
-	var statusMutex sync.Mutex
-
-	queue := make(chan string)
+// Message is a single outgoing copy of an email, delivered to To only. The
+// To header lists VisibleTo, or To alone when VisibleTo is empty, and the Cc
+// header lists CC. Bcc recipients are simply never named in the headers.
+type Message struct {
+	To          string
+	VisibleTo   []string
+	CC          []string
+	ReplyTo     []string
+	Template    *Template
+	Data        EmailData
+	Attachments []*data.Attachment
+}
 
-	var wg sync.WaitGroup
+func (m Mailer) Send(message *Message) error {
+	emailData := message.Data
 
-	email := &data.Email{
-		Sender:  sender,
-		Body:    body,
-		Subject: subject,
+	if m.tracker != nil && emailData.Token != "" {
+		emailData.OpenURL = m.tracker.OpenURL(emailData.Token)
+		emailData.UnsubscribeURL = m.tracker.UnsubscribeURL(emailData.Token)
+		emailData.PreferencesURL = m.tracker.PreferencesURL(emailData.Token)
 	}
 
-	for i := 0; i < 10; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for recipient := range queue {
-
-				tmpl, err := template.ParseFiles("./internal/mailer/email_template.tmpl")
-				
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-				url:= os.Getenv("URL")
-				fmt.Println("URL: ", url)
-
-				emailId, err := e.InsertEmail(email, recipient)
-				if err != nil {
-					log.Println(err)
-					return
-				}
-
-				data := EmailData{
-					Subject:   subject,
-					Body:      body,
-					Recipient: recipient,
-					EmailId: emailId,
-					URL: url,
-				}
+	content, err := ParseContent(emailData.Subject, emailData.Body)
+	if err != nil {
+		return err
+	}
 
-				bodyBuf := new(bytes.Buffer)
-				err = tmpl.ExecuteTemplate(bodyBuf, "htmlBody", data)
+	emailData.Subject, emailData.Body, err = content.render(emailData)
+	if err != nil {
+		return err
+	}
 
-				if err != nil {
-					log.Println(err)				
-					continue
-				}
+	// The email's own subject wins over the template's subject block. The
+	// send handler copies a stored template's subject into the email when
+	// the request has none, so that block is still what gets sent then.
+	_, plainBody, htmlBody, err := message.Template.render(emailData)
+	if err != nil {
+		return err
+	}
+	subject := emailData.Subject
 
-				m := mail.NewMessage()
-				m.SetHeader("From", sender)
-				m.SetHeader("To", recipient)
-				m.SetHeader("Subject", subject)
-				m.SetBody("text/html", bodyBuf.String()) 
+	if emailData.UnsubscribeURL != "" {
+		plainBody, htmlBody = addUnsubscribeLink(plainBody, htmlBody, emailData.UnsubscribeURL)
+		htmlBody = m.tracker.rewriteLinks(htmlBody, emailData.Token)
+	}
 
-				err = d.DialAndSend(m)
+	msg := mail.NewMessage()
+	msg.SetHeader("From", m.sender)
+	if len(message.VisibleTo) > 0 {
+		msg.SetHeader("To", message.VisibleTo...)
+	} else {
+		msg.SetHeader("To", message.To)
+	}
+	if len(message.CC) > 0 {
+		msg.SetHeader("Cc", message.CC...)
+	}
+	if len(message.ReplyTo) > 0 {
+		msg.SetHeader("Reply-To", message.ReplyTo...)
+	}
+	msg.SetHeader("Subject", subject)
+	if emailData.Token != "" {
+		msg.SetHeader("Message-ID", m.messageID(emailData.Token))
+	}
+	if emailData.UnsubscribeURL != "" {
+		// RFC 8058 one-click unsubscribe.
+		msg.SetHeader("List-Unsubscribe", "<"+emailData.UnsubscribeURL+">")
+		msg.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	msg.SetBody("text/plain", plainBody)
+	msg.AddAlternative("text/html", htmlBody)
 
-				if err != nil {
-					fmt.Println("Failed to send test email to -> " + recipient + ": " + err.Error())
-				} else {
-					fmt.Println("Sent test email successfully to -> " + recipient)
-					statusMutex.Lock()
-					emailStatuses[recipient].Sent = true
-					statusMutex.Unlock()
-					err := e.UpdateEmailStatus(emailId)
-					if err != nil {
-						log.Println(err)
-						return
-					}
-				}
-			}
-		}()
+	for _, a := range message.Attachments {
+		attach(msg, a)
 	}
 
-	for _, recipient := range recipients {
-		queue <- recipient
+	return m.pool.send(msg, m.envelopeFrom(), []string{message.To})
+}
 
-		statusMutex.Lock()
-		emailStatuses[recipient] = &EmailStatus{
-			Sent:     false,
-			Opened:   false,
-			SentTime: time.Now(),
-		}
+// envelopeFrom returns the bare address of the sender, which may be
+// configured as "Name <address>".
+func (m Mailer) envelopeFrom() string {
+	addr, err := netmail.ParseAddress(m.sender)
+	if err != nil {
+		return m.sender
+	}
+	return addr.Address
+}
 
-		statusMutex.Unlock()
+// messageID embeds the recipient's tracking token in the Message-ID, so that
+// bounces quoting the original headers can be matched to the recipient.
+func (m Mailer) messageID(token string) string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(m.envelopeFrom(), "@"); ok {
+		domain = d
 	}
-	close(queue)
+	return "<" + token + "@" + domain + ">"
+}
 
-	wg.Wait()
+// attach adds a to msg, inline when it has a Content-ID. The content is
+// written through a copy func rather than a reader so that the message can be
+// written again if the pool has to resend it on a fresh connection.
+func attach(msg *mail.Message, a *data.Attachment) {
+	content := a.Content
+	contentType := mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Filename})
+
+	settings := []mail.FileSetting{
+		mail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(content)
+			return err
+		}),
+	}
 
-	for recipient, status := range emailStatuses {
-		log.Printf("Email to %s: sent=%v, opened=%v, sentTime=%v", recipient, status.Sent, status.Opened, status.SentTime)
+	if a.ContentID == "" {
+		settings = append(settings, mail.SetHeader(map[string][]string{
+			"Content-Type": {contentType},
+		}))
+		msg.Attach(a.Filename, settings...)
+		return
 	}
 
-	return emailStatuses, nil
+	settings = append(settings, mail.SetHeader(map[string][]string{
+		"Content-Type": {contentType},
+		"Content-ID":   {"<" + a.ContentID + ">"},
+	}))
+	msg.Embed(a.Filename, settings...)
 }
 
-func UpdateEmailTracking(e data.EmailModel, emailid int64) error {
-	return e.UpdateEmail(emailid)
+// Close closes the idle connections held by the pool. Connections in use are
+// closed as soon as they are returned.
+func (m Mailer) Close() {
+	m.pool.close()
 }
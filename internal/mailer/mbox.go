@@ -0,0 +1,64 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// ReadMbox splits an mbox file into raw messages. Every message starts with a
+// "From " separator line, which is dropped, and ">From " quoting in the body
+// is undone. Lines of any length are accepted.
+func ReadMbox(r io.Reader) ([][]byte, error) {
+	var (
+		messages [][]byte
+		current  *bytes.Buffer
+		long     []byte
+	)
+
+	br := bufio.NewReader(r)
+
+	for {
+		line, isPrefix, err := br.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// ReadLine hands over a line longer than its buffer in pieces, and
+		// each piece is only valid until the next read.
+		if isPrefix || long != nil {
+			long = append(long, line...)
+			if isPrefix {
+				continue
+			}
+			line, long = long, nil
+		}
+
+		if bytes.HasPrefix(line, []byte("From ")) {
+			if current != nil {
+				messages = append(messages, current.Bytes())
+			}
+			current = new(bytes.Buffer)
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			line = line[1:]
+		}
+		current.Write(line)
+		current.WriteString("\r\n")
+	}
+
+	if current != nil {
+		messages = append(messages, current.Bytes())
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,122 @@
+package mailer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadMbox(t *testing.T) {
+	mbox := "stray line before the first separator\n" +
+		"From MAILER-DAEMON Tue May 14 09:12:03 2024\n" +
+		"Subject: one\n" +
+		"\n" +
+		">From the quoted body\n" +
+		">>From a twice quoted line\n" +
+		"> From is not a separator\n" +
+		"From MAILER-DAEMON Tue May 14 09:13:00 2024\r\n" +
+		"Subject: two\r\n" +
+		"\r\n" +
+		"last line without a newline"
+
+	messages, err := ReadMbox(strings.NewReader(mbox))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"Subject: one\r\n\r\nFrom the quoted body\r\n>From a twice quoted line\r\n> From is not a separator\r\n",
+		"Subject: two\r\n\r\nlast line without a newline\r\n",
+	}
+
+	if len(messages) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(messages), len(want))
+	}
+	for i := range want {
+		if string(messages[i]) != want[i] {
+			t.Errorf("message %d = %q, want %q", i, messages[i], want[i])
+		}
+	}
+}
+
+func TestReadMboxEmpty(t *testing.T) {
+	messages, err := ReadMbox(strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 {
+		t.Errorf("got %d messages, want none", len(messages))
+	}
+}
+
+// A single line longer than any scanner buffer must not stop the messages
+// that follow it from being read.
+func TestReadMboxLongLine(t *testing.T) {
+	long := strings.Repeat("x", 3<<20)
+
+	mbox := "From a Tue May 14 09:12:03 2024\n" +
+		"Subject: long\n\n" + long + "\n" +
+		"From b Tue May 14 09:13:00 2024\n" +
+		"Subject: after\n\nok\n"
+
+	messages, err := ReadMbox(strings.NewReader(mbox))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	if want := "Subject: long\r\n\r\n" + long + "\r\n"; string(messages[0]) != want {
+		t.Errorf("long message has %d bytes, want %d", len(messages[0]), len(want))
+	}
+	if want := "Subject: after\r\n\r\nok\r\n"; string(messages[1]) != want {
+		t.Errorf("message 1 = %q, want %q", messages[1], want)
+	}
+}
+
+// Every fixture survives a round trip through an mbox file, including a
+// "From " line in the body that the mailbox quoted.
+func TestReadMboxNotifications(t *testing.T) {
+	files := []string{"postfix_failed.eml", "postfix_delayed.eml", "exim_failed.eml", "gmail_failed.eml", "base64_soft.eml"}
+
+	var mbox bytes.Buffer
+
+	for _, file := range files {
+		raw, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mbox.WriteString("From MAILER-DAEMON Tue May 14 09:12:03 2024\n")
+		for _, line := range strings.SplitAfter(string(raw), "\n") {
+			if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+				mbox.WriteString(">")
+			}
+			mbox.WriteString(line)
+		}
+		mbox.WriteString("\n")
+	}
+
+	messages, err := ReadMbox(&mbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != len(files) {
+		t.Fatalf("got %d messages, want %d", len(messages), len(files))
+	}
+
+	for i, raw := range messages {
+		dsn, err := ParseDSN(bytes.NewReader(raw))
+		if err != nil {
+			t.Errorf("%s: ParseDSN: %v", files[i], err)
+			continue
+		}
+		if dsn.Bounce() == nil {
+			t.Errorf("%s: Bounce() = nil", files[i])
+		}
+	}
+}
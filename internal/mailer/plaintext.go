@@ -0,0 +1,70 @@
+package mailer
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	invisibleRx = regexp.MustCompile(`(?is)<(head|style|script)\b.*?</(head|style|script)>`)
+	anchorRx    = regexp.MustCompile(`(?is)<a\b[^>]*?\bhref\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+	lineBreakRx = regexp.MustCompile(`(?i)<br\s*/?>`)
+	blockEndRx  = regexp.MustCompile(`(?i)</(p|div|h[1-6]|tr|table|blockquote|ul|ol)>`)
+	listItemRx  = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	cellEndRx   = regexp.MustCompile(`(?i)</(td|th)\s*>`)
+	imgAltRx    = regexp.MustCompile(`(?is)<img\b[^>]*?\balt\s*=\s*["']([^"']*)["'][^>]*>`)
+	tagRx       = regexp.MustCompile(`(?s)<[^>]*>`)
+	spacesRx    = regexp.MustCompile(`\s+`)
+	blankRx     = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText produces a readable plain-text version of an HTML body for the
+// text/plain alternative part. Links are kept as numbered footnotes so that
+// text-only clients and screen readers can still follow them, and list items
+// become "- " lines.
+func htmlToText(body string) string {
+	body = invisibleRx.ReplaceAllString(body, "")
+	body = spacesRx.ReplaceAllString(body, " ")
+	body = imgAltRx.ReplaceAllString(body, "$1")
+
+	var links []string
+
+	body = anchorRx.ReplaceAllStringFunc(body, func(a string) string {
+		m := anchorRx.FindStringSubmatch(a)
+		href := strings.TrimSpace(html.UnescapeString(m[1]))
+		text := strings.TrimSpace(tagRx.ReplaceAllString(m[2], ""))
+
+		if href == "" || strings.HasPrefix(href, "#") {
+			return text
+		}
+
+		links = append(links, href)
+		return strings.TrimSpace(fmt.Sprintf("%s [%d]", text, len(links)))
+	})
+
+	body = lineBreakRx.ReplaceAllString(body, "\n")
+	body = listItemRx.ReplaceAllString(body, "\n- ")
+	body = cellEndRx.ReplaceAllString(body, " ")
+	body = blockEndRx.ReplaceAllString(body, "\n\n")
+	body = tagRx.ReplaceAllString(body, "")
+	body = html.UnescapeString(body)
+
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	text := blankRx.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	text = strings.TrimSpace(text)
+
+	if len(links) > 0 {
+		text += "\n\n"
+		for i, link := range links {
+			text += fmt.Sprintf("[%d] %s\n", i+1, link)
+		}
+	}
+
+	return text
+}
@@ -0,0 +1,75 @@
+package mailer
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "plain text",
+			html: "Hello",
+			want: "Hello",
+		},
+		{
+			name: "entities",
+			html: "<p>Fish &amp; chips&nbsp;&mdash; &lt;b&gt; is &#34;bold&#34; &copy; 2024</p>",
+			want: "Fish & chips — <b> is \"bold\" © 2024",
+		},
+		{
+			name: "line breaks",
+			html: "one<br>two<BR/>three<br />four",
+			want: "one\ntwo\nthree\nfour",
+		},
+		{
+			name: "paragraphs",
+			html: "<p>First\n   paragraph</p>\n\n<p>Second</p><div>Third</div><h1>Title</h1>",
+			want: "First paragraph\n\nSecond\n\nThird\n\nTitle",
+		},
+		{
+			name: "links",
+			html: `<p>See <a href="https://example.com/a?x=1&amp;y=2">the <b>docs</b></a> or <a class="btn" href='https://example.com/b'>this</a>.</p>`,
+			want: "See the docs [1] or this [2].\n\n[1] https://example.com/a?x=1&y=2\n[2] https://example.com/b\n",
+		},
+		{
+			name: "anchors and empty links",
+			html: `<a href="#top">Top</a> <a href="">Nowhere</a>`,
+			want: "Top Nowhere",
+		},
+		{
+			name: "image alt text",
+			html: `<p><img src="logo.png" alt="ScholarX"> <img src="spacer.gif"></p>`,
+			want: "ScholarX",
+		},
+		{
+			name: "style, script and head",
+			html: "<html><head><title>Hidden</title><style>p { color: red; }</style></head><body><script type=\"text/javascript\">var a = \"<p>\";</script><p>Shown</p><STYLE>\n.x{}\n</STYLE></body></html>",
+			want: "Shown",
+		},
+		{
+			name: "lists",
+			html: "<p>Steps:</p><ol><li>Apply</li><li>Interview</li></ol><p>Done</p>",
+			want: "Steps:\n\n- Apply\n- Interview\n\nDone",
+		},
+		{
+			name: "nested lists",
+			html: "<ul>\n  <li>Mentors\n    <ul>\n      <li>Alice</li>\n      <li>Bob</li>\n    </ul>\n  </li>\n  <li>Mentees</li>\n</ul>",
+			want: "- Mentors\n- Alice\n- Bob\n\n- Mentees",
+		},
+		{
+			name: "tables",
+			html: "<table><tr><td>Name</td><td>Alice</td></tr><tr><td>Cohort</td><td>2024</td></tr></table>",
+			want: "Name Alice\n\nCohort 2024",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := htmlToText(tt.html); got != tt.want {
+				t.Errorf("htmlToText(%q)\n got %q\nwant %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package mailer
+
+import (
+	"errors"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+)
+
+// idleTimeout is how long an unused connection is kept before it is closed
+// instead of reused. SMTP servers drop idle clients after a few minutes, so
+// this stays well below that.
+const idleTimeout = 30 * time.Second
+
+// pool keeps authenticated SMTP connections open between messages, so the
+// TCP, TLS and AUTH handshakes are paid once per connection rather than once
+// per recipient. At most cap(slots) connections are open at any time.
+type pool struct {
+	dial  func() (mail.SendCloser, error)
+	slots chan struct{}
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+type conn struct {
+	mail.SendCloser
+	lastUsed time.Time
+}
+
+func newPool(dial func() (mail.SendCloser, error), maxConns int) *pool {
+	if maxConns < 1 {
+		maxConns = 1
+	}
+
+	return &pool{
+		dial:  dial,
+		slots: make(chan struct{}, maxConns),
+	}
+}
+
+// send delivers msg to the envelope recipients in to over a pooled
+// connection. The envelope is given explicitly rather than derived from the
+// headers, so each copy of an email only goes to its own recipient. A connection that errors is
+// closed rather than returned to the pool. If a reused connection fails
+// without an SMTP reply, the server most likely hung up on it while idle, so
+// the message is tried once more on a freshly dialled one.
+func (p *pool) send(msg *mail.Message, from string, to []string) error {
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+
+	c, reused, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	err = c.Send(from, to, msg)
+	if err != nil && reused && !isReply(err) {
+		c.Close()
+
+		c, err = p.open()
+		if err != nil {
+			return err
+		}
+		err = c.Send(from, to, msg)
+	}
+
+	if err != nil {
+		c.Close()
+		return err
+	}
+
+	p.put(c)
+	return nil
+}
+
+func (p *pool) get() (*conn, bool, error) {
+	p.mu.Lock()
+	for len(p.idle) > 0 {
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+
+		if time.Since(c.lastUsed) < idleTimeout {
+			p.mu.Unlock()
+			return c, true, nil
+		}
+		c.Close()
+	}
+	p.mu.Unlock()
+
+	c, err := p.open()
+	return c, false, err
+}
+
+func (p *pool) open() (*conn, error) {
+	sc, err := p.dial()
+	if err != nil {
+		return nil, &dialError{err}
+	}
+	return &conn{SendCloser: sc}, nil
+}
+
+func (p *pool) put(c *conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		c.Close()
+		return
+	}
+
+	c.lastUsed = time.Now()
+	p.idle = append(p.idle, c)
+}
+
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for _, c := range p.idle {
+		c.Close()
+	}
+	p.idle = nil
+}
+
+// dialError is a failure to connect to, greet, start TLS with or
+// authenticate to the SMTP server. It happens before any recipient is named,
+// so it says nothing about the recipient.
+type dialError struct {
+	err error
+}
+
+func (e *dialError) Error() string {
+	return "connecting to the SMTP server: " + e.err.Error()
+}
+
+func (e *dialError) Unwrap() error {
+	return e.err
+}
+
+// cause unwraps a *mail.SendError, which does not implement Unwrap itself.
+func cause(err error) error {
+	var sendErr *mail.SendError
+	if errors.As(err, &sendErr) {
+		return sendErr.Cause
+	}
+	return err
+}
+
+// isReply reports whether err is an SMTP reply from the server, as opposed to
+// a failure of the connection itself.
+func isReply(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(cause(err), &protoErr)
+}
@@ -0,0 +1,143 @@
+package mailer
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+)
+
+// handshakeDelay stands in for the TCP+TLS+AUTH round trips of a real SMTP
+// server, which is the cost the pool exists to avoid.
+const handshakeDelay = 2 * time.Millisecond
+
+// smtpServer starts a minimal SMTP server that accepts every message.
+func smtpServer(b *testing.B) *mail.Dialer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(c)
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return mail.NewDialer("127.0.0.1", addr.Port, "", "")
+}
+
+func serveSMTP(c net.Conn) {
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	reply := func(line string) {
+		w.WriteString(line + "\r\n")
+		w.Flush()
+	}
+
+	time.Sleep(handshakeDelay)
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+			}
+			reply("250 ok")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func message() *mail.Message {
+	msg := mail.NewMessage()
+	msg.SetHeader("From", "scholarx@sefglobal.org")
+	msg.SetHeader("To", "mentee@example.com")
+	msg.SetHeader("Subject", "ScholarX announcement")
+	msg.SetBody("text/html", "<p>Hello</p>")
+	return msg
+}
+
+const recipients, workers = 1000, 10
+
+// sendAll delivers 1,000 messages from 10 concurrent workers, mirroring the
+// queue's default worker pool.
+func sendAll(b *testing.B, send func(*mail.Message) error) {
+	queue := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range queue {
+				if err := send(message()); err != nil {
+					b.Error(err)
+				}
+			}
+		}()
+	}
+
+	for n := 0; n < recipients; n++ {
+		queue <- n
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func BenchmarkDialAndSend1000(b *testing.B) {
+	d := smtpServer(b)
+
+	for i := 0; i < b.N; i++ {
+		sendAll(b, func(msg *mail.Message) error {
+			return d.DialAndSend(msg)
+		})
+	}
+
+	b.ReportMetric(float64(recipients*b.N)/b.Elapsed().Seconds(), "msgs/s")
+}
+
+func BenchmarkPool1000(b *testing.B) {
+	d := smtpServer(b)
+	p := newPool(d.Dial, 5)
+	defer p.close()
+
+	for i := 0; i < b.N; i++ {
+		sendAll(b, func(msg *mail.Message) error {
+			return p.send(msg, "scholarx@sefglobal.org", []string{"mentee@example.com"})
+		})
+	}
+
+	b.ReportMetric(float64(recipients*b.N)/b.Elapsed().Seconds(), "msgs/s")
+}
@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/textproto"
+	"time"
+)
+
+// Backoff describes an exponential retry schedule. The delay before retry n
+// (starting at 1) is Base*2^(n-1), capped at Max, then spread by up to
+// ±Jitter (a fraction between 0 and 1) so that recipients which failed
+// together do not all retry at the same instant.
+type Backoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Base
+	for i := 1; i < attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+
+	if b.Jitter > 0 {
+		spread := float64(delay) * b.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+
+	return delay
+}
+
+// IsTemporary reports whether a send error is worth retrying. SMTP replies in
+// the 4xx range are transient by definition, 5xx replies are permanent.
+// Failing to connect or authenticate, a rejected login included, and
+// dropped connections are about the server rather than the recipient, so
+// they are transient; anything else (bad addresses, template errors) will
+// not succeed on a second try.
+func IsTemporary(err error) bool {
+	err = cause(err)
+
+	var dialErr *dialError
+	if errors.As(err, &dialErr) {
+		return true
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return (protoErr.Code >= 400 && protoErr.Code < 500) || authReply(protoErr.Code)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.EOF)
+}
+
+// authReply reports whether code is one of the RFC 4954 replies about the
+// client's own authentication: 530 authentication required, 534 mechanism
+// too weak, 535 credentials invalid and 538 encryption required.
+func authReply(code int) bool {
+	switch code {
+	case 530, 534, 535, 538:
+		return true
+	}
+	return false
+}
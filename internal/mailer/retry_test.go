@@ -0,0 +1,229 @@
+package mailer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Base: 30 * time.Second, Max: time.Hour}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := b.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffDelayMaxBelowBase(t *testing.T) {
+	b := Backoff{Base: time.Minute, Max: 10 * time.Second}
+
+	if got := b.Delay(1); got != 10*time.Second {
+		t.Errorf("Delay(1) = %v, want the 10s cap", got)
+	}
+}
+
+func TestBackoffDelayJitter(t *testing.T) {
+	b := Backoff{Base: time.Minute, Max: time.Hour, Jitter: 0.2}
+
+	low, high := 48*time.Second, 72*time.Second
+	seen := make(map[time.Duration]bool)
+
+	for i := 0; i < 1000; i++ {
+		got := b.Delay(1)
+		if got < low || got > high {
+			t.Fatalf("Delay(1) = %v, want between %v and %v", got, low, high)
+		}
+		seen[got] = true
+	}
+
+	if len(seen) < 2 {
+		t.Error("Delay(1) returned the same value every time")
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	reply := func(code int, msg string) error {
+		return &textproto.Error{Code: code, Msg: msg}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"421 service unavailable", reply(421, "4.3.2 Service not available, closing channel"), true},
+		{"450 mailbox busy", reply(450, "4.2.1 Mailbox busy"), true},
+		{"451 greylisted", reply(451, "4.7.1 Greylisted, try again later"), true},
+		{"452 mailbox full", reply(452, "4.2.2 Mailbox full"), true},
+		{"550 no such user", reply(550, "5.1.1 User unknown"), false},
+		{"552 message too big", reply(552, "5.3.4 Message size exceeds fixed limit"), false},
+		{"553 bad address", reply(553, "5.1.3 Invalid address"), false},
+		{"554 rejected", reply(554, "5.7.1 Message rejected as spam"), false},
+		{"530 authentication required", reply(530, "5.7.0 Authentication required"), true},
+		{"534 mechanism too weak", reply(534, "5.7.9 Authentication mechanism is too weak"), true},
+		{"535 bad credentials", reply(535, "5.7.8 Username and Password not accepted"), true},
+		{"538 encryption required", reply(538, "5.7.11 Encryption required"), true},
+		{"send error around a 4xx", &mail.SendError{Cause: reply(451, "4.3.0 Try again")}, true},
+		{"send error around a 5xx", &mail.SendError{Cause: reply(550, "5.1.1 User unknown")}, false},
+		{"dial error around a 554 greeting", &dialError{reply(554, "5.3.2 No service")}, true},
+		{"dial error around a TLS failure", &dialError{errors.New("tls: failed to verify certificate")}, true},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")}, true},
+		{"dropped connection", io.EOF, true},
+		{"wrapped dropped connection", fmt.Errorf("sending: %w", io.EOF), true},
+		{"template error", errors.New(`template: htmlBody:1: function "nope" not defined`), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTemporary(tt.err); got != tt.want {
+				t.Errorf("IsTemporary(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIsTemporaryPool classifies the errors the pool returns for the replies
+// of a real SMTP conversation.
+func TestIsTemporaryPool(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies map[string]string
+		want    bool
+	}{
+		{"rejected login", map[string]string{"AUTH": "535 5.7.8 Authentication credentials invalid"}, true},
+		{"busy server", map[string]string{"GREETING": "421 4.3.2 Too many connections"}, true},
+		{"greylisted recipient", map[string]string{"RCPT": "451 4.7.1 Greylisted"}, true},
+		{"unknown recipient", map[string]string{"RCPT": "550 5.1.1 User unknown"}, false},
+		{"rejected message", map[string]string{".": "554 5.7.1 Rejected as spam"}, false},
+		{"full mailbox", map[string]string{".": "452 4.2.2 Mailbox full"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := scriptedServer(t, tt.replies)
+			p := newPool(d.Dial, 1)
+			defer p.close()
+
+			err := p.send(message(), "scholarx@sefglobal.org", []string{"mentee@example.com"})
+			if err == nil {
+				t.Fatal("send succeeded, want an error")
+			}
+			if got := IsTemporary(err); got != tt.want {
+				t.Errorf("IsTemporary(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+
+	d := scriptedServer(t, nil)
+	p := newPool(d.Dial, 1)
+	defer p.close()
+
+	if err := p.send(message(), "scholarx@sefglobal.org", []string{"mentee@example.com"}); err != nil {
+		t.Errorf("send = %v, want no error", err)
+	}
+}
+
+// scriptedServer starts an SMTP server that offers AUTH PLAIN and answers
+// every command with success unless replies overrides it. Replies are keyed
+// by command, with GREETING for the banner and "." for the end of the data.
+func scriptedServer(t *testing.T, replies map[string]string) *mail.Dialer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	reply := func(w *bufio.Writer, key, fallback string) bool {
+		line, ok := replies[key]
+		if !ok {
+			line = fallback
+		}
+		w.WriteString(line + "\r\n")
+		w.Flush()
+		return !ok
+	}
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer c.Close()
+
+				r := bufio.NewReader(c)
+				w := bufio.NewWriter(c)
+
+				if !reply(w, "GREETING", "220 localhost ESMTP") {
+					return
+				}
+
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+
+					cmd := strings.ToUpper(strings.TrimSpace(line))
+					if i := strings.IndexAny(cmd, " :"); i >= 0 {
+						cmd = cmd[:i]
+					}
+
+					switch cmd {
+					case "EHLO":
+						reply(w, cmd, "250-localhost\r\n250 AUTH PLAIN")
+					case "AUTH":
+						reply(w, cmd, "235 2.7.0 Authentication successful")
+					case "DATA":
+						if !reply(w, cmd, "354 go ahead") {
+							continue
+						}
+						for {
+							line, err := r.ReadString('\n')
+							if err != nil {
+								return
+							}
+							if line == ".\r\n" {
+								break
+							}
+						}
+						reply(w, ".", "250 ok")
+					case "QUIT":
+						reply(w, cmd, "221 bye")
+						return
+					default:
+						reply(w, cmd, "250 ok")
+					}
+				}
+			}()
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return mail.NewDialer("127.0.0.1", addr.Port, "scholarx", "secret")
+}
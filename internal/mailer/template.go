@@ -0,0 +1,328 @@
+package mailer
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"text/template/parse"
+
+	"github.com/mayura-andrew/email-client/internal/data"
+)
+
+// Template is a parsed email template made of three blocks: "subject",
+// "plainBody" and "htmlBody". The subject and plain text blocks go through
+// text/template so they are not HTML-escaped; the HTML block goes through
+// html/template.
+type Template struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+	vars []string
+}
+
+// ParseTemplate parses the three blocks of a stored template.
+func ParseTemplate(subject, plainBody, htmlBody string) (*Template, error) {
+	text := texttemplate.New("template")
+
+	_, err := text.New("subject").Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = text.New("plainBody").Parse(plainBody)
+	if err != nil {
+		return nil, err
+	}
+
+	html, err := htmltemplate.New("htmlBody").Parse(htmlBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTemplate(text, html), nil
+}
+
+// parseTemplateFile parses a file that defines the three blocks itself, like
+// email_template.tmpl.
+func parseTemplateFile(path string) (*Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := texttemplate.New("template").Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	html, err := htmltemplate.New("template").Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	return newTemplate(text, html), nil
+}
+
+// newTemplate collects the variables of a freshly parsed template. This has to
+// happen before the first execution: html/template rewrites its trees while
+// escaping them.
+func newTemplate(text *texttemplate.Template, html *htmltemplate.Template) *Template {
+	seen := make(map[string]bool)
+
+	for _, t := range text.Templates() {
+		if t.Tree != nil {
+			collectVariables(t.Tree.Root, true, seen)
+		}
+	}
+	for _, t := range html.Templates() {
+		if t.Tree != nil {
+			collectVariables(t.Tree.Root, true, seen)
+		}
+	}
+
+	return &Template{text: text, html: html, vars: sortedVariables(seen)}
+}
+
+func sortedVariables(seen map[string]bool) []string {
+	vars := make([]string, 0, len(seen))
+	for name := range seen {
+		vars = append(vars, name)
+	}
+	sort.Strings(vars)
+
+	return vars
+}
+
+// Content is the subject and body of an email as given in the send request.
+// Both are templates themselves, with the same merge fields as the blocks of
+// a Template, and are executed before the template that lays them out.
+type Content struct {
+	text *texttemplate.Template
+	vars []string
+}
+
+// ParseContent parses the subject and body of a send request.
+func ParseContent(subject, body string) (*Content, error) {
+	text := texttemplate.New("content")
+
+	_, err := text.New("subject").Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = text.New("body").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range text.Templates() {
+		if t.Tree != nil {
+			collectVariables(t.Tree.Root, true, seen)
+		}
+	}
+
+	return &Content{text: text, vars: sortedVariables(seen)}, nil
+}
+
+// Variables returns the sorted names of the .Data fields the subject and body
+// use, like Template.Variables.
+func (c *Content) Variables() []string {
+	return c.vars
+}
+
+func (c *Content) render(data any) (subject, body string, err error) {
+	buf := new(bytes.Buffer)
+
+	err = c.text.ExecuteTemplate(buf, "subject", data)
+	if err != nil {
+		return "", "", err
+	}
+	subject = buf.String()
+
+	buf.Reset()
+
+	err = c.text.ExecuteTemplate(buf, "body", data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return subject, buf.String(), nil
+}
+
+// Variables returns the sorted names of the .Data fields the template uses
+// unconditionally. Fields that are only used as the condition of an if, with
+// or range block are optional, since the template already handles them being
+// absent.
+func (t *Template) Variables() []string {
+	return t.vars
+}
+
+// collectVariables walks a template tree. dot reports whether dot is still
+// the email data: inside a with or range block it is not, and only
+// references through $ count.
+func collectVariables(node parse.Node, dot bool, seen map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectVariables(child, dot, seen)
+		}
+	case *parse.ActionNode:
+		collectPipe(n.Pipe, dot, seen)
+	case *parse.IfNode:
+		collectVariables(n.List, dot, seen)
+		collectVariables(n.ElseList, dot, seen)
+	case *parse.WithNode:
+		collectVariables(n.List, false, seen)
+		collectVariables(n.ElseList, dot, seen)
+	case *parse.RangeNode:
+		collectVariables(n.List, false, seen)
+		collectVariables(n.ElseList, dot, seen)
+	}
+}
+
+// collectPipe records the variables named by .Data.x, $.Data.x and
+// index .Data "x" in every command of pipe.
+func collectPipe(pipe *parse.PipeNode, dot bool, seen map[string]bool) {
+	if pipe == nil {
+		return
+	}
+
+	for _, cmd := range pipe.Cmds {
+		if len(cmd.Args) >= 3 && isIdentifier(cmd.Args[0], "index") && isData(cmd.Args[1], dot) {
+			if key, ok := cmd.Args[2].(*parse.StringNode); ok {
+				seen[key.Text] = true
+			}
+		}
+
+		for _, arg := range cmd.Args {
+			switch a := arg.(type) {
+			case *parse.FieldNode:
+				if dot && len(a.Ident) >= 2 && a.Ident[0] == "Data" {
+					seen[a.Ident[1]] = true
+				}
+			case *parse.VariableNode:
+				if len(a.Ident) >= 3 && a.Ident[0] == "$" && a.Ident[1] == "Data" {
+					seen[a.Ident[2]] = true
+				}
+			case *parse.PipeNode:
+				collectPipe(a, dot, seen)
+			}
+		}
+	}
+}
+
+func isIdentifier(node parse.Node, name string) bool {
+	ident, ok := node.(*parse.IdentifierNode)
+	return ok && ident.Ident == name
+}
+
+// isData reports whether node is .Data or $.Data.
+func isData(node parse.Node, dot bool) bool {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return dot && len(n.Ident) == 1 && n.Ident[0] == "Data"
+	case *parse.VariableNode:
+		return len(n.Ident) == 2 && n.Ident[0] == "$" && n.Ident[1] == "Data"
+	}
+	return false
+}
+
+// render executes every block. When the plain text block is empty, the text
+// part is generated from the rendered HTML instead.
+func (t *Template) render(data any) (subject, plainBody, htmlBody string, err error) {
+	buf := new(bytes.Buffer)
+
+	err = t.text.ExecuteTemplate(buf, "subject", data)
+	if err != nil {
+		return "", "", "", err
+	}
+	subject = buf.String()
+
+	buf.Reset()
+
+	err = t.html.ExecuteTemplate(buf, "htmlBody", data)
+	if err != nil {
+		return "", "", "", err
+	}
+	htmlBody = buf.String()
+
+	buf.Reset()
+
+	if t.text.Lookup("plainBody") != nil {
+		err = t.text.ExecuteTemplate(buf, "plainBody", data)
+		if err != nil {
+			return "", "", "", err
+		}
+	}
+	plainBody = strings.TrimSpace(buf.String())
+
+	if plainBody == "" {
+		plainBody = htmlToText(htmlBody)
+	}
+
+	return subject, plainBody, htmlBody, nil
+}
+
+type templateKey struct {
+	id      int64
+	version int
+}
+
+// templateCache holds parsed templates so that they are parsed once rather
+// than for every recipient. The built-in template file uses the zero key.
+// A stored version never changes, so entries never go stale.
+type templateCache struct {
+	mu        sync.RWMutex
+	templates map[templateKey]*Template
+}
+
+func newTemplateCache() *templateCache {
+	return &templateCache{templates: make(map[templateKey]*Template)}
+}
+
+func (c *templateCache) get(key templateKey, parse func() (*Template, error)) (*Template, error) {
+	c.mu.RLock()
+	t, ok := c.templates[key]
+	c.mu.RUnlock()
+
+	if ok {
+		return t, nil
+	}
+
+	t, err := parse()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.templates[key] = t
+	c.mu.Unlock()
+
+	return t, nil
+}
+
+// DefaultTemplate returns the built-in ScholarX template.
+func (m Mailer) DefaultTemplate() (*Template, error) {
+	return m.templates.get(templateKey{}, func() (*Template, error) {
+		return parseTemplateFile(templateFile)
+	})
+}
+
+// Template returns version of the stored template id, calling load to fetch
+// it from the database on a cache miss.
+func (m Mailer) Template(id int64, version int, load func(id int64, version int) (*data.Template, error)) (*Template, error) {
+	return m.templates.get(templateKey{id, version}, func() (*Template, error) {
+		t, err := load(id, version)
+		if err != nil {
+			return nil, err
+		}
+		return ParseTemplate(t.Subject, t.PlainBody, t.HTMLBody)
+	})
+}
@@ -0,0 +1,113 @@
+package mailer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTemplateVariables(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"none", `Hello`, []string{}},
+		{"field", `{{.Data.name}}`, []string{"name"}},
+		{"nested field", `{{.Data.mentor.name}}`, []string{"mentor"}},
+		{"root variable", `{{$.Data.name}}`, []string{"name"}},
+		{"index", `{{index .Data "first-name"}}`, []string{"first-name"}},
+		{"index on root", `{{index $.Data "cohort"}}`, []string{"cohort"}},
+		{"index with a variable key", `{{$k := "a"}}{{index .Data $k}}`, []string{}},
+		{"pipeline", `{{.Data.name | printf "%s"}}`, []string{"name"}},
+		{"parenthesised", `{{printf "%s" (.Data.name)}}`, []string{"name"}},
+		{"declaration", `{{$n := .Data.name}}{{$n}}`, []string{"name"}},
+		{"other fields", `{{.Recipient}} {{.Subject}}`, []string{}},
+		{"if condition is optional", `{{if .Data.name}}hi{{end}}`, []string{}},
+		{"if body", `{{if .Data.vip}}{{.Data.code}}{{else}}{{.Data.fallback}}{{end}}`, []string{"code", "fallback"}},
+		{"with condition is optional", `{{with .Data.name}}{{.}}{{end}}`, []string{}},
+		{"with body uses the new dot", `{{with .Data.mentor}}{{.Data.name}}{{end}}`, []string{}},
+		{"with body through root", `{{with .Data.mentor}}{{$.Data.cohort}}{{end}}`, []string{"cohort"}},
+		{"with body index through root", `{{with .Data.mentor}}{{index $.Data "cohort"}}{{end}}`, []string{"cohort"}},
+		{"with else keeps the dot", `{{with .Data.name}}{{.}}{{else}}{{.Data.email}}{{end}}`, []string{"email"}},
+		{"range body through root", `{{range .Data.items}}{{.Data.x}}{{$.Data.unit}}{{end}}`, []string{"unit"}},
+		{"range else keeps the dot", `{{range .Data.items}}{{.}}{{else}}{{.Data.none}}{{end}}`, []string{"none"}},
+		{"nested blocks", `{{with .Data.a}}{{if $.Data.b}}{{range $.Data.c}}{{$.Data.d}}{{end}}{{end}}{{end}}`, []string{"d"}},
+		{"sorted and unique", `{{.Data.b}}{{.Data.a}}{{$.Data.b}}`, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate("Subject", tt.body, "<p>Hello</p>")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := tmpl.Variables(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Variables() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Variables are collected from every block, including the HTML one, whose
+// tree html/template rewrites once it is executed.
+func TestTemplateVariablesAllBlocks(t *testing.T) {
+	tmpl, err := ParseTemplate(`{{.Data.subject}}`, `{{.Data.plain}}`, `<a href="{{.Data.link}}">{{$.Data.html}}</a>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = tmpl.render(EmailData{Data: map[string]any{"subject": "s", "plain": "p", "link": "https://example.com", "html": "h"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"html", "link", "plain", "subject"}
+	if got := tmpl.Variables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %q, want %q", got, want)
+	}
+}
+
+func TestContent(t *testing.T) {
+	content, err := ParseContent("Hi {{.Data.name}}", `{{with .Data.mentor}}Your mentor is {{.}}.{{end}} Cohort {{index .Data "cohort"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"cohort", "name"}; !reflect.DeepEqual(content.Variables(), want) {
+		t.Errorf("Variables() = %q, want %q", content.Variables(), want)
+	}
+
+	subject, body, err := content.render(EmailData{Data: map[string]any{"name": "Alice", "mentor": "Bob", "cohort": "2024"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Hi Alice" {
+		t.Errorf("subject = %q, want %q", subject, "Hi Alice")
+	}
+	if want := "Your mentor is Bob. Cohort 2024"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+
+	// The rendered subject and body then go through the built-in template.
+	tmpl, err := parseTemplateFile("email_template.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, plainBody, _, err := tmpl.render(EmailData{Subject: subject, Body: body, Data: map[string]any{"name": "Alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered != "Hi Alice" {
+		t.Errorf("built-in subject = %q, want %q", rendered, "Hi Alice")
+	}
+	if !strings.Contains(plainBody, "Your mentor is Bob.") {
+		t.Errorf("built-in plain body does not contain the rendered body: %q", plainBody)
+	}
+
+	if _, err := ParseContent("Hi {{.Data.name", ""); err == nil {
+		t.Error("ParseContent accepted an unclosed action")
+	}
+}
@@ -0,0 +1,131 @@
+package mailer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Tracker builds the open and click tracking URLs for a recipient. Click URLs
+// carry the destination together with an HMAC over the recipient's token and
+// the destination, so the click endpoint only ever redirects to links that
+// were actually sent and can't be used as an open redirect.
+type Tracker struct {
+	baseURL string
+	secret  []byte
+}
+
+func NewTracker(baseURL string, secret []byte) *Tracker {
+	return &Tracker{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+	}
+}
+
+// OpenURL returns the address of the tracking pixel.
+func (t *Tracker) OpenURL(token string) string {
+	return t.baseURL + "/api/v1/t/open/" + url.PathEscape(token)
+}
+
+// ClickURL returns the tracked replacement for a link to target.
+func (t *Tracker) ClickURL(token, target string) string {
+	qs := url.Values{
+		"url": {target},
+		"sig": {t.sign(token, target)},
+	}
+	return t.baseURL + "/api/v1/t/click/" + url.PathEscape(token) + "?" + qs.Encode()
+}
+
+// UnsubscribeURL returns the signed one-click unsubscribe address of a
+// recipient.
+func (t *Tracker) UnsubscribeURL(token string) string {
+	return t.baseURL + "/api/v1/unsubscribe/" + url.PathEscape(token) + "?sig=" + t.sign(token, unsubscribeTarget)
+}
+
+// VerifyUnsubscribe reports whether sig was produced by UnsubscribeURL for
+// token.
+func (t *Tracker) VerifyUnsubscribe(token, sig string) bool {
+	return hmac.Equal([]byte(sig), []byte(t.sign(token, unsubscribeTarget)))
+}
+
+// PreferencesURL returns the signed address of a recipient's preference
+// center.
+func (t *Tracker) PreferencesURL(token string) string {
+	return t.baseURL + "/preferences/" + url.PathEscape(token) + "?sig=" + t.sign(token, preferencesTarget)
+}
+
+// VerifyPreferences reports whether sig was produced by PreferencesURL for
+// token.
+func (t *Tracker) VerifyPreferences(token, sig string) bool {
+	return hmac.Equal([]byte(sig), []byte(t.sign(token, preferencesTarget)))
+}
+
+// unsubscribeTarget and preferencesTarget stand in for the destination when
+// signing unsubscribe and preference center links. They are not URLs, so
+// they can never match a signed click.
+const (
+	unsubscribeTarget = "unsubscribe"
+	preferencesTarget = "preferences"
+)
+
+// VerifyClick reports whether sig was produced by ClickURL for token and
+// target.
+func (t *Tracker) VerifyClick(token, target, sig string) bool {
+	return hmac.Equal([]byte(sig), []byte(t.sign(token, target)))
+}
+
+func (t *Tracker) sign(token, target string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(token))
+	mac.Write([]byte{0})
+	mac.Write([]byte(target))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+var hrefRx = regexp.MustCompile(`(?is)(<a\s[^>]*?\bhref\s*=\s*)("[^"]*"|'[^']*')`)
+
+// rewriteLinks points every http and https link of a rendered HTML body at
+// the click endpoint. Other schemes, such as mailto: and cid:, and links to
+// this API itself, like the unsubscribe link, are left alone.
+func (t *Tracker) rewriteLinks(body, token string) string {
+	return hrefRx.ReplaceAllStringFunc(body, func(match string) string {
+		m := hrefRx.FindStringSubmatch(match)
+		quote := m[2][:1]
+		target := html.UnescapeString(m[2][1 : len(m[2])-1])
+
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || strings.HasPrefix(target, t.baseURL+"/") {
+			return match
+		}
+
+		return m[1] + quote + html.EscapeString(t.ClickURL(token, target)) + quote
+	})
+}
+
+var bodyCloseRx = regexp.MustCompile(`(?i)</body\s*>`)
+
+// addUnsubscribeLink makes sure both bodies carry the unsubscribe link,
+// appending it to templates that don't place it themselves.
+func addUnsubscribeLink(plainBody, htmlBody, unsubscribeURL string) (string, string) {
+	if !strings.Contains(plainBody, unsubscribeURL) {
+		plainBody += "\n\nUnsubscribe: " + unsubscribeURL
+	}
+
+	escaped := html.EscapeString(unsubscribeURL)
+
+	if !strings.Contains(htmlBody, escaped) {
+		link := `<p style="font-size: 12px; text-align: center"><a href="` + escaped + `">Unsubscribe</a></p>`
+
+		if loc := bodyCloseRx.FindStringIndex(htmlBody); loc != nil {
+			htmlBody = htmlBody[:loc[0]] + link + htmlBody[loc[0]:]
+		} else {
+			htmlBody += link
+		}
+	}
+
+	return plainBody, htmlBody
+}
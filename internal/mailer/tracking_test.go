@@ -0,0 +1,175 @@
+package mailer
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testToken = "k3n9QzX1b7"
+
+func newTestTracker() *Tracker {
+	return NewTracker("https://mail.example.com/", []byte("0123456789abcdef"))
+}
+
+func TestRewriteLinks(t *testing.T) {
+	tr := newTestTracker()
+
+	click := func(target string) string {
+		return html.EscapeString(tr.ClickURL(testToken, target))
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "double quotes",
+			body: `<a href="https://example.com/a">a</a>`,
+			want: `<a href="` + click("https://example.com/a") + `">a</a>`,
+		},
+		{
+			name: "single quotes",
+			body: `<a href='http://example.com/a'>a</a>`,
+			want: `<a href='` + click("http://example.com/a") + `'>a</a>`,
+		},
+		{
+			name: "escaped query",
+			body: `<a href="https://example.com/?a=1&amp;b=2">a</a>`,
+			want: `<a href="` + click("https://example.com/?a=1&b=2") + `">a</a>`,
+		},
+		{
+			name: "other attributes and case",
+			body: `<A class="btn" HREF = "https://example.com/a" target="_blank">a</A>`,
+			want: `<A class="btn" HREF = "` + click("https://example.com/a") + `" target="_blank">a</A>`,
+		},
+		{
+			name: "mailto",
+			body: `<a href="mailto:help@example.com">help</a>`,
+			want: `<a href="mailto:help@example.com">help</a>`,
+		},
+		{
+			name: "cid",
+			body: `<a href="cid:logo">logo</a>`,
+			want: `<a href="cid:logo">logo</a>`,
+		},
+		{
+			name: "relative",
+			body: `<a href="/about">about</a>`,
+			want: `<a href="/about">about</a>`,
+		},
+		{
+			name: "self link",
+			body: `<a href="` + html.EscapeString(tr.UnsubscribeURL(testToken)) + `">unsubscribe</a>`,
+			want: `<a href="` + html.EscapeString(tr.UnsubscribeURL(testToken)) + `">unsubscribe</a>`,
+		},
+		{
+			name: "not a link",
+			body: `<img src="https://example.com/logo.png"><link href="https://example.com/a.css">`,
+			want: `<img src="https://example.com/logo.png"><link href="https://example.com/a.css">`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tr.rewriteLinks(tt.body, testToken); got != tt.want {
+				t.Errorf("rewriteLinks(%q)\n got %q\nwant %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyClick(t *testing.T) {
+	tr := newTestTracker()
+
+	u, err := url.Parse(tr.ClickURL(testToken, "https://example.com/?a=1&b=2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(u.Path, "/api/v1/t/click/"+testToken) {
+		t.Fatalf("ClickURL path = %q", u.Path)
+	}
+
+	target, sig := u.Query().Get("url"), u.Query().Get("sig")
+
+	tests := []struct {
+		name   string
+		tr     *Tracker
+		token  string
+		target string
+		sig    string
+		want   bool
+	}{
+		{"valid", tr, testToken, target, sig, true},
+		{"tampered url", tr, testToken, "https://evil.example.net/", sig, false},
+		{"tampered sig", tr, testToken, target, tamper(sig), false},
+		{"empty sig", tr, testToken, target, "", false},
+		{"other token", tr, "Vb2pL0s8Qe", target, sig, false},
+		{"other secret", NewTracker("https://mail.example.com", []byte("fedcba9876543210")), testToken, target, sig, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tr.VerifyClick(tt.token, tt.target, tt.sig); got != tt.want {
+				t.Errorf("VerifyClick = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyUnsubscribeAndPreferences(t *testing.T) {
+	tr := newTestTracker()
+
+	unsubscribe, _ := url.Parse(tr.UnsubscribeURL(testToken))
+	preferences, _ := url.Parse(tr.PreferencesURL(testToken))
+
+	if !tr.VerifyUnsubscribe(testToken, unsubscribe.Query().Get("sig")) {
+		t.Error("VerifyUnsubscribe rejected its own signature")
+	}
+	if !tr.VerifyPreferences(testToken, preferences.Query().Get("sig")) {
+		t.Error("VerifyPreferences rejected its own signature")
+	}
+	if tr.VerifyUnsubscribe(testToken, preferences.Query().Get("sig")) {
+		t.Error("VerifyUnsubscribe accepted a preferences signature")
+	}
+	if tr.VerifyPreferences(testToken, unsubscribe.Query().Get("sig")) {
+		t.Error("VerifyPreferences accepted an unsubscribe signature")
+	}
+}
+
+// tamper flips the last character of a signature.
+func tamper(sig string) string {
+	last := "A"
+	if strings.HasSuffix(sig, last) {
+		last = "B"
+	}
+	return sig[:len(sig)-1] + last
+}
+
+func TestDefaultTemplateOpenPixel(t *testing.T) {
+	tmpl, err := parseTemplateFile("email_template.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	openURL := newTestTracker().OpenURL(testToken)
+
+	_, _, htmlBody, err := tmpl.render(EmailData{Subject: "Hi", Body: "Hello", Recipient: "alice@example.com", OpenURL: openURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(htmlBody, `<img src="`+openURL+`"`) {
+		t.Errorf("HTML body does not embed the pixel %q", openURL)
+	}
+
+	_, _, htmlBody, err = tmpl.render(EmailData{Subject: "Hi", Body: "Hello", Recipient: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(htmlBody, "/api/v1/t/open/") {
+		t.Error("HTML body embeds a pixel without an OpenURL")
+	}
+}
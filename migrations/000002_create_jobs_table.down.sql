@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS recipients_pending_idx;
+
+ALTER TABLE recipients DROP COLUMN IF EXISTS locked_until;
+
+DROP TABLE IF EXISTS jobs;
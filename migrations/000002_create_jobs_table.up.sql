@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    email_id INTEGER NOT NULL REFERENCES emails(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    completed_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+ALTER TABLE recipients ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS recipients_pending_idx ON recipients (email_id) WHERE status = false;
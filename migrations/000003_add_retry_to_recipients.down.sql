@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS recipients_pending_idx;
+CREATE INDEX IF NOT EXISTS recipients_pending_idx ON recipients (email_id) WHERE status = false;
+
+ALTER TABLE recipients DROP COLUMN IF EXISTS failed;
+ALTER TABLE recipients DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE recipients DROP COLUMN IF EXISTS last_error;
+ALTER TABLE recipients DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,7 @@
+ALTER TABLE recipients ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE recipients ADD COLUMN last_error TEXT;
+ALTER TABLE recipients ADD COLUMN next_attempt_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE recipients ADD COLUMN failed BOOLEAN NOT NULL DEFAULT FALSE;
+
+DROP INDEX IF EXISTS recipients_pending_idx;
+CREATE INDEX IF NOT EXISTS recipients_pending_idx ON recipients (email_id) WHERE status = false AND failed = false;
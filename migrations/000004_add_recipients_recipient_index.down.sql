@@ -0,0 +1 @@
+DROP INDEX IF EXISTS recipients_recipient_idx;
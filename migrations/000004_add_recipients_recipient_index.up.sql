@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS recipients_recipient_idx ON recipients (LOWER(recipient));
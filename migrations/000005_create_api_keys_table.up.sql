@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    name VARCHAR(255) NOT NULL,
+    hash BYTEA NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    revoked_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+ALTER TABLE emails ADD COLUMN api_key_id BIGINT REFERENCES api_keys(id);
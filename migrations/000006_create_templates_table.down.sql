@@ -0,0 +1,6 @@
+ALTER TABLE emails DROP COLUMN IF EXISTS template_data;
+ALTER TABLE emails DROP COLUMN IF EXISTS template_version;
+ALTER TABLE emails DROP COLUMN IF EXISTS template_id;
+
+DROP TABLE IF EXISTS template_versions;
+DROP TABLE IF EXISTS templates;
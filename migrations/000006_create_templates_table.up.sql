@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS templates (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    name VARCHAR(255) NOT NULL UNIQUE,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS template_versions (
+    template_id BIGINT NOT NULL REFERENCES templates(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    subject TEXT NOT NULL,
+    plain_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    PRIMARY KEY (template_id, version)
+);
+
+ALTER TABLE emails ADD COLUMN template_id BIGINT REFERENCES templates(id);
+ALTER TABLE emails ADD COLUMN template_version INTEGER;
+ALTER TABLE emails ADD COLUMN template_data JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    email_id INTEGER NOT NULL REFERENCES emails(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    content_id VARCHAR(255) NOT NULL DEFAULT '',
+    size INTEGER NOT NULL,
+    content BYTEA NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS attachments_email_id_idx ON attachments (email_id);
@@ -0,0 +1,3 @@
+ALTER TABLE emails DROP COLUMN IF EXISTS reply_to;
+
+ALTER TABLE recipients DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,3 @@
+ALTER TABLE recipients ADD COLUMN kind VARCHAR(3) NOT NULL DEFAULT 'to';
+
+ALTER TABLE emails ADD COLUMN reply_to TEXT[] NOT NULL DEFAULT '{}';
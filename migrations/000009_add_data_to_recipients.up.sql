@@ -0,0 +1 @@
+ALTER TABLE recipients ADD COLUMN data JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS emails_scheduled_idx;
+
+ALTER TABLE emails DROP COLUMN IF EXISTS state;
+ALTER TABLE emails DROP COLUMN IF EXISTS send_at;
@@ -0,0 +1,4 @@
+ALTER TABLE emails ADD COLUMN send_at TIMESTAMP(0) WITH TIME ZONE;
+ALTER TABLE emails ADD COLUMN state VARCHAR(20) NOT NULL DEFAULT 'queued';
+
+CREATE INDEX IF NOT EXISTS emails_scheduled_idx ON emails (send_at) WHERE state = 'scheduled';
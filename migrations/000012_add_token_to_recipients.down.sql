@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS recipients_token_idx;
+
+ALTER TABLE recipients DROP COLUMN IF EXISTS token;
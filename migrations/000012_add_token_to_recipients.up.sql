@@ -0,0 +1,3 @@
+ALTER TABLE recipients ADD COLUMN token VARCHAR(26);
+
+CREATE UNIQUE INDEX IF NOT EXISTS recipients_token_idx ON recipients (token);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS clicks (
+    id BIGSERIAL PRIMARY KEY,
+    recipient_id INTEGER NOT NULL REFERENCES recipients(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    clicked_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS clicks_recipient_id_idx ON clicks (recipient_id);
@@ -0,0 +1,5 @@
+ALTER TABLE recipients DROP COLUMN IF EXISTS machine_open_count;
+ALTER TABLE recipients DROP COLUMN IF EXISTS open_count;
+ALTER TABLE recipients DROP COLUMN IF EXISTS first_opened_at;
+
+DROP TABLE IF EXISTS email_events;
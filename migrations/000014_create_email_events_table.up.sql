@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS email_events (
+    id BIGSERIAL PRIMARY KEY,
+    recipient_id INTEGER NOT NULL REFERENCES recipients(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    machine BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS email_events_recipient_id_idx ON email_events (recipient_id);
+
+ALTER TABLE recipients ADD COLUMN first_opened_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE recipients ADD COLUMN open_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE recipients ADD COLUMN machine_open_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE recipients SET first_opened_at = opened_time, open_count = 1 WHERE opened = true;
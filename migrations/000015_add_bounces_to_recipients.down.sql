@@ -0,0 +1,4 @@
+ALTER TABLE recipients DROP COLUMN IF EXISTS bounced_at;
+ALTER TABLE recipients DROP COLUMN IF EXISTS bounce_diagnostic;
+ALTER TABLE recipients DROP COLUMN IF EXISTS bounce_status;
+ALTER TABLE recipients DROP COLUMN IF EXISTS bounce_type;
@@ -0,0 +1,4 @@
+ALTER TABLE recipients ADD COLUMN bounce_type VARCHAR(4) NOT NULL DEFAULT '';
+ALTER TABLE recipients ADD COLUMN bounce_status VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE recipients ADD COLUMN bounce_diagnostic TEXT NOT NULL DEFAULT '';
+ALTER TABLE recipients ADD COLUMN bounced_at TIMESTAMP WITH TIME ZONE;
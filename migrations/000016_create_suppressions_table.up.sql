@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS suppressions (
+    id BIGSERIAL PRIMARY KEY,
+    address VARCHAR(255) NOT NULL UNIQUE,
+    reason VARCHAR(20) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO suppressions (address, reason, source)
+SELECT DISTINCT LOWER(recipient), 'hard_bounce', 'bounce' FROM recipients WHERE bounce_type = 'hard'
+ON CONFLICT DO NOTHING;
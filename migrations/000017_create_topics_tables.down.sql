@@ -0,0 +1,3 @@
+ALTER TABLE emails DROP COLUMN IF EXISTS topic_id;
+DROP TABLE IF EXISTS topic_preferences;
+DROP TABLE IF EXISTS topics;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS topics (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO topics (name, description) VALUES
+    ('announcements', 'News and announcements about ScholarX'),
+    ('reminders', 'Weekly reminders about your mentoring sessions'),
+    ('mentor-updates', 'Updates from your mentor')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS topic_preferences (
+    address VARCHAR(255) NOT NULL,
+    topic_id BIGINT NOT NULL REFERENCES topics ON DELETE CASCADE,
+    subscribed BOOLEAN NOT NULL,
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (address, topic_id)
+);
+
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS topic_id BIGINT REFERENCES topics ON DELETE SET NULL;
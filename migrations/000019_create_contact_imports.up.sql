@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS contact_imports (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    list_id BIGINT REFERENCES lists ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    size BIGINT NOT NULL,
+    bytes_processed BIGINT NOT NULL DEFAULT 0,
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    accepted INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    invalid INTEGER NOT NULL DEFAULT 0,
+    added BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    completed_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS contact_import_rows (
+    import_id BIGINT NOT NULL REFERENCES contact_imports ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    address TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (import_id, row_number)
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS recipients_pending_idx;
+CREATE INDEX IF NOT EXISTS recipients_pending_idx ON recipients (email_id) WHERE status = false AND failed = false;
+
+ALTER TABLE recipients DROP COLUMN IF EXISTS skipped;
@@ -0,0 +1,4 @@
+ALTER TABLE recipients ADD COLUMN skipped VARCHAR(20) NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS recipients_pending_idx;
+CREATE INDEX IF NOT EXISTS recipients_pending_idx ON recipients (email_id) WHERE status = false AND failed = false AND skipped = '';